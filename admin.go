@@ -0,0 +1,385 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/walkingeyerobot/r38/drafts"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// setNameRegexp keeps set names from escaping the sets directory.
+var setNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// insertMargin is how much of a create draft request is kept back from generating the
+// draft so there's still time to insert it.
+const insertMargin = time.Second
+
+// ServeAPIAdminCreateDraft serves the /api/admin/createdraft endpoint.
+// It generates and inserts a draft the same way the makedraft command does.
+func ServeAPIAdminCreateDraft(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	if r.Method != "POST" {
+		// we have to return an error manually here because we want to return
+		// a different http status code.
+		tx.Rollback()
+		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if !config.IsAdmin(userID) {
+		return fmt.Errorf("auth error in create draft")
+	}
+
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading post body: %s", err.Error())
+	}
+	var toCreate PostedCreateDraft
+	err = json.Unmarshal(bodyBytes, &toCreate)
+	if err != nil {
+		return fmt.Errorf("error parsing post body: %s", err.Error())
+	}
+
+	if len(toCreate.RoundSets) > 0 && len(toCreate.ChaosSets) > 0 {
+		return fmt.Errorf("roundSets and chaosSets can't be used together")
+	}
+	if toCreate.Cube != "" && (len(toCreate.RoundSets) > 0 || len(toCreate.ChaosSets) > 0) {
+		return fmt.Errorf("cube can't be used with roundSets or chaosSets")
+	}
+	spectators, err := drafts.ParseSpectatorMode(toCreate.Spectators)
+	if err != nil {
+		return err
+	}
+
+	// Every set shares what's left of the request, less time to insert the draft,
+	// so generating never outlives the transaction.
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		deadline = time.Now().Add(config.RequestTimeout)
+	}
+	deadline = deadline.Add(-insertMargin)
+	if time.Until(deadline) <= 0 {
+		return fmt.Errorf("not enough time left to generate a draft")
+	}
+
+	var names []string
+	var mixed makedraft.MixedDraft
+	switch {
+	case len(toCreate.RoundSets) > 0:
+		if len(toCreate.RoundSets) != 3 {
+			return fmt.Errorf("roundSets needs a set for each of 3 rounds")
+		}
+		for _, name := range toCreate.RoundSets {
+			index := len(names)
+			for i, seen := range names {
+				if seen == name {
+					index = i
+				}
+			}
+			if index == len(names) {
+				names = append(names, name)
+			}
+			mixed.Rounds = append(mixed.Rounds, index)
+		}
+	case len(toCreate.ChaosSets) > 0:
+		names = toCreate.ChaosSets
+	case toCreate.Cube != "":
+	default:
+		names = []string{toCreate.Set}
+	}
+
+	// sets_dir is only needed here, so a server that only makes drafts from cubes doesn't
+	// need one.
+	if len(names) > 0 {
+		info, err := os.Stat(config.SetsDir)
+		if err != nil {
+			return fmt.Errorf("can't read sets_dir %q: %s", config.SetsDir, err.Error())
+		} else if !info.IsDir() {
+			return fmt.Errorf("sets_dir %q is not a directory", config.SetsDir)
+		}
+	}
+	for _, name := range names {
+		if !setNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid set name %q", name)
+		}
+		set, err := makedraft.LoadDraftSet(filepath.Join(config.SetsDir, name+".json"), toCreate.Flags)
+		if err != nil {
+			return err
+		}
+		set.Settings.Deadline = deadline
+		mixed.Sets = append(mixed.Sets, set)
+	}
+
+	var version makedraft.CubeVersion
+	if toCreate.Cube != "" {
+		var cfg makedraft.DraftConfig
+		cfg, version, err = makedraft.LoadCubeVersion(tx, toCreate.Cube, toCreate.CubeVersion)
+		if err != nil {
+			return err
+		}
+		set, err := makedraft.NewDraftSet(toCreate.Cube, cfg, toCreate.Flags)
+		if err != nil {
+			return err
+		}
+		set.Settings.Deadline = deadline
+		mixed.Sets = append(mixed.Sets, set)
+		names = append(names, fmt.Sprintf("%s version %d", version.Cube, version.Version))
+	}
+
+	settings := mixed.Sets[0].Settings
+	if toCreate.Name != "" {
+		settings.Name = toCreate.Name
+	}
+	if toCreate.Seed != 0 {
+		settings.Seed = toCreate.Seed
+	}
+
+	var draft makedraft.GeneratedDraft
+	if len(mixed.Sets) == 1 && len(mixed.Rounds) == 0 {
+		draft, err = makedraft.GenerateDraft(mixed.Sets[0].Config, settings)
+	} else {
+		draft, err = makedraft.GenerateMixedDraft(mixed, settings.Seed)
+	}
+	if err != nil {
+		return fmt.Errorf("error generating draft: %s", err.Error())
+	}
+
+	draftID, err := makedraft.InsertDraft(tx, settings.Name, draft.Packs)
+	if err != nil {
+		return fmt.Errorf("error inserting draft: %s", err.Error())
+	}
+	if version.ID != 0 {
+		err = drafts.SetCubeVersion(tx, draftID, version.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if toCreate.DisableSuggestions {
+		err = drafts.SetSuggestions(tx, draftID, false)
+		if err != nil {
+			return err
+		}
+	}
+	if spectators.Mode != drafts.SpectateLive {
+		err = drafts.SetSpectators(tx, draftID, spectators)
+		if err != nil {
+			return err
+		}
+	}
+	var inviteLink string
+	if toCreate.Private {
+		invite, err := drafts.SetPrivate(tx, draftID, true)
+		if err != nil {
+			return err
+		}
+		inviteLink = joinLink(invite)
+	}
+
+	log.Printf("user %d created draft %d from sets %v", userID, draftID, names)
+
+	json.NewEncoder(w).Encode(CreatedDraft{
+		DraftID:       draftID,
+		DraftAttempts: draft.DraftAttempts,
+		PackAttempts:  draft.PackAttempts,
+		CubeVersion:   version.Version,
+		InviteLink:    inviteLink,
+	})
+	return nil
+}
+
+// ServeAPIAdminCubeVersion serves the /api/admin/cubeversion endpoint.
+// It saves the next version of a cube, either from a whole set json file or
+// as adds and cuts to the latest version.
+func ServeAPIAdminCubeVersion(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	if r.Method != "POST" {
+		// we have to return an error manually here because we want to return
+		// a different http status code.
+		tx.Rollback()
+		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if !config.IsAdmin(userID) {
+		return fmt.Errorf("auth error in cube version")
+	}
+
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading post body: %s", err.Error())
+	}
+	var posted PostedCubeVersion
+	err = json.Unmarshal(bodyBytes, &posted)
+	if err != nil {
+		return fmt.Errorf("error parsing post body: %s", err.Error())
+	}
+
+	var version makedraft.CubeVersion
+	if posted.Set != nil {
+		if len(posted.Adds) > 0 || len(posted.Cuts) > 0 {
+			return fmt.Errorf("send either a set or adds and cuts, not both")
+		}
+		version, err = makedraft.SaveCubeVersion(tx, posted.Cube, posted.Note, *posted.Set)
+	} else {
+		version, err = makedraft.ChangeCube(tx, posted.Cube, posted.Note, posted.Adds, posted.Cuts)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving cube version: %s", err.Error())
+	}
+
+	log.Printf("user %d saved %s version %d", userID, version.Cube, version.Version)
+
+	json.NewEncoder(w).Encode(version)
+	return nil
+}
+
+// ServeAPIAdminSuggestions serves the /api/admin/suggestions endpoint.
+// It turns pick suggestions on or off for a draft.
+func ServeAPIAdminSuggestions(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	if r.Method != "POST" {
+		// we have to return an error manually here because we want to return
+		// a different http status code.
+		tx.Rollback()
+		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if !config.IsAdmin(userID) {
+		return fmt.Errorf("auth error in suggestions")
+	}
+
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading post body: %s", err.Error())
+	}
+	var posted PostedSuggestions
+	err = json.Unmarshal(bodyBytes, &posted)
+	if err != nil {
+		return fmt.Errorf("error parsing post body: %s", err.Error())
+	}
+
+	err = drafts.SetSuggestions(tx, posted.DraftID, posted.Enabled)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("user %d set suggestions for draft %d to %v", userID, posted.DraftID, posted.Enabled)
+
+	json.NewEncoder(w).Encode(posted)
+	return nil
+}
+
+// ServeAPIAdminSpectators serves the /api/admin/spectators endpoint.
+// It sets how people who aren't in a draft can watch it while it's running.
+func ServeAPIAdminSpectators(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	if r.Method != "POST" {
+		// we have to return an error manually here because we want to return
+		// a different http status code.
+		tx.Rollback()
+		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if !config.IsAdmin(userID) {
+		return fmt.Errorf("auth error in spectators")
+	}
+
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading post body: %s", err.Error())
+	}
+	var posted PostedSpectators
+	err = json.Unmarshal(bodyBytes, &posted)
+	if err != nil {
+		return fmt.Errorf("error parsing post body: %s", err.Error())
+	}
+
+	mode, err := drafts.ParseSpectatorMode(posted.Spectators)
+	if err != nil {
+		return err
+	}
+	err = drafts.SetSpectators(tx, posted.DraftID, mode)
+	if err != nil {
+		return err
+	}
+	posted.Spectators = mode.String()
+
+	log.Printf("user %d set spectators for draft %d to %s", userID, posted.DraftID, posted.Spectators)
+
+	json.NewEncoder(w).Encode(posted)
+	return nil
+}
+
+// ServeAPIAdminInvite serves the /api/admin/invite endpoint.
+// It shows a draft's invite code, makes the draft private or public, or makes a new
+// invite code or revokes it so nobody else can join.
+func ServeAPIAdminInvite(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	if r.Method != "POST" {
+		// we have to return an error manually here because we want to return
+		// a different http status code.
+		tx.Rollback()
+		http.Error(w, "invalid request method", http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	if !config.IsAdmin(userID) {
+		return fmt.Errorf("auth error in invite")
+	}
+
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("error reading post body: %s", err.Error())
+	}
+	var posted PostedInvite
+	err = json.Unmarshal(bodyBytes, &posted)
+	if err != nil {
+		return fmt.Errorf("error parsing post body: %s", err.Error())
+	}
+
+	invite, err := drafts.GetInvite(tx, posted.DraftID)
+	if err != nil {
+		return err
+	}
+	switch posted.Action {
+	case "show":
+	case "private":
+		if !invite.Private {
+			invite, err = drafts.SetPrivate(tx, posted.DraftID, true)
+		}
+	case "public":
+		invite, err = drafts.SetPrivate(tx, posted.DraftID, false)
+	case "regenerate":
+		invite, err = drafts.SetPrivate(tx, posted.DraftID, true)
+	case "revoke":
+		invite, err = drafts.RevokeInviteCode(tx, posted.DraftID)
+	default:
+		return fmt.Errorf("unknown invite action %q, use show, private, public, regenerate or revoke", posted.Action)
+	}
+	if err != nil {
+		return err
+	}
+
+	if posted.Action != "show" {
+		log.Printf("user %d did %s on the invite for draft %d", userID, posted.Action, posted.DraftID)
+	}
+
+	json.NewEncoder(w).Encode(InviteCode{Invite: invite, Link: joinLink(invite)})
+	return nil
+}
+
+// joinLink returns the link players can follow to join a private draft, or nothing
+// if it doesn't have an invite code.
+func joinLink(invite drafts.Invite) string {
+	if invite.Code == "" {
+		return ""
+	}
+	return fmt.Sprintf("/join/%d?code=%s", invite.DraftID, url.QueryEscape(invite.Code))
+}
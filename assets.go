@@ -0,0 +1,58 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+)
+
+// The templates and static files are compiled into the binary so it can run from
+// anywhere. Build the client with `npm run deploy` first so static/dist is included.
+
+//go:embed index.tmpl login.tmpl vue.tmpl
+var embeddedTemplates embed.FS
+
+//go:embed static
+var embeddedStatic embed.FS
+
+var templates *template.Template
+
+// LoadTemplates parses the embedded templates once at startup.
+// In dev mode templates are read from disk on every request instead.
+func LoadTemplates() error {
+	if config.Dev {
+		return nil
+	}
+	var err error
+	templates, err = template.ParseFS(embeddedTemplates, "*.tmpl")
+	return err
+}
+
+// ExecuteTemplate renders one of the .tmpl files.
+func ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	t := templates
+	if config.Dev {
+		var err error
+		t, err = template.ParseFiles(filepath.Join(config.TemplateDir, name))
+		if err != nil {
+			return err
+		}
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+// StaticFileSystem returns what's served under /static/.
+// In dev mode it's read straight from disk so rebuilt client bundles show up immediately.
+func StaticFileSystem() (http.FileSystem, error) {
+	if config.Dev {
+		return http.Dir(config.StaticDir), nil
+	}
+	static, err := fs.Sub(embeddedStatic, "static")
+	if err != nil {
+		return nil, err
+	}
+	return http.FS(static), nil
+}
@@ -77,19 +77,28 @@ func oauthDiscordCallback(w http.ResponseWriter, r *http.Request, userID int64,
 	if p.Picture != "" {
 		p.Picture = fmt.Sprintf("https://cdn.discordapp.com/avatars/%v/%s.png", p.ID, p.Picture)
 	} else {
-		p.Picture = "http://draft.thefoley.net/static/favicon.png"
+		p.Picture = config.BaseURL + "/static/favicon.png"
 	}
 
-	statement, err := tx.Prepare(`INSERT INTO users (discord_id, discord_name, picture) VALUES (?, ?, ?)`)
+	// A failed insert aborts the whole transaction on postgres, so only insert
+	// users we haven't seen before.
+	row := tx.QueryRow(`SELECT count(1) FROM users WHERE discord_id = ?`, p.ID)
+	var existingUsers int64
+	err = row.Scan(&existingUsers)
 	if err != nil {
 		return err
 	}
-	statement.Exec(p.ID, p.Name, p.Picture)
+	if existingUsers == 0 {
+		_, err = tx.Exec(`INSERT INTO users (discord_id, discord_name, picture) VALUES (?, ?, ?)`, p.ID, p.Name, p.Picture)
+		if err != nil {
+			return err
+		}
+	}
 
 	// BEGIN MIGRATION BLOCK
 	// delete this block when migration to discord oauth is deemed complete
 
-	row := tx.QueryRow(`SELECT id FROM users_old WHERE slack="<@" || ? || ">"`, p.ID)
+	row = tx.QueryRow(`SELECT id FROM users_old WHERE slack='<@' || ? || '>'`, p.ID)
 	var oldUserID int64
 	err = row.Scan(&oldUserID)
 	if err == sql.ErrNoRows {
@@ -99,7 +108,11 @@ func oauthDiscordCallback(w http.ResponseWriter, r *http.Request, userID int64,
 		return err
 	} else {
 		// we found the old user
-		_, err = tx.Exec(`delete from users where id=? and discord_id is null; UPDATE users set id=? where discord_id=?`, oldUserID, oldUserID, p.ID)
+		_, err = tx.Exec(`delete from users where id=? and discord_id is null`, oldUserID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE users set id=? where discord_id=?`, oldUserID, p.ID)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+
+	"github.com/walkingeyerobot/r38/db"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// runCatalog implements `makedraft catalog`, which moves cards from drafts made before
+// the card catalog existed into it.
+func runCatalog(args []string) int {
+	flagSet := flag.NewFlagSet("makedraft catalog", flag.ContinueOnError)
+	databasePath := flagSet.String(
+		"database", "draft.db",
+		"The sqlite3 database file or postgres connection string to use.")
+	databaseDriver := flagSet.String(
+		"database-driver", db.SQLite,
+		"The database driver to use, either sqlite3 or postgres.")
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	database, err := openDatabase(*databaseDriver, *databasePath)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+	tx, err := database.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		log.Printf("can't create a context: %s", err.Error())
+		return 1
+	}
+	defer tx.Rollback()
+
+	moved, err := makedraft.MoveCardsToCatalog(tx)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("can't commit :( %s", err.Error())
+		return 1
+	}
+	log.Printf("moved %d cards into the catalog.", moved)
+	return 0
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/walkingeyerobot/r38/db"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// openDatabase opens the database and brings its schema up to date.
+func openDatabase(driver string, path string) (*sql.DB, error) {
+	database, err := db.Open(driver, path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %s: %s", path, err.Error())
+	}
+	err = db.Migrate(database, driver)
+	if err != nil {
+		return nil, fmt.Errorf("error migrating database %s: %s", path, err.Error())
+	}
+	return database, nil
+}
+
+// runCube implements `makedraft cube`, which saves, lists and compares versions
+// of cube lists stored in the database.
+func runCube(args []string) int {
+	usage := "usage: makedraft cube save|list|diff -cube=name [flags]"
+	if len(args) == 0 {
+		log.Printf("%s", usage)
+		return 2
+	}
+	command := args[0]
+
+	flagSet := flag.NewFlagSet("makedraft cube "+command, flag.ContinueOnError)
+	cube := flagSet.String(
+		"cube", "",
+		"The name of the cube.")
+	databasePath := flagSet.String(
+		"database", "draft.db",
+		"The sqlite3 database file or postgres connection string to use.")
+	databaseDriver := flagSet.String(
+		"database-driver", db.SQLite,
+		"The database driver to use, either sqlite3 or postgres.")
+	set := flagSet.String(
+		"set", "",
+		"For save, the .json set file to save as the cube's next version.")
+	note := flagSet.String(
+		"note", "",
+		"For save, a note about what changed in this version.")
+	from := flagSet.Int64(
+		"from", 0,
+		"For diff, the version to compare from. Defaults to the version before -to.")
+	to := flagSet.Int64(
+		"to", 0,
+		"For diff, the version to compare to. Defaults to the latest.")
+
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *cube == "" {
+		log.Printf("you must specify a -cube")
+		return 2
+	}
+
+	database, err := openDatabase(*databaseDriver, *databasePath)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+	tx, err := database.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: command != "save"})
+	if err != nil {
+		log.Printf("can't create a context: %s", err.Error())
+		return 1
+	}
+	defer tx.Rollback()
+
+	switch command {
+	case "save":
+		if *set == "" {
+			log.Printf("you must specify a -set json file to save")
+			return 2
+		}
+		cfg, err := makedraft.LoadDraftConfig(*set)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return 1
+		}
+		version, err := makedraft.SaveCubeVersion(tx, *cube, *note, cfg)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return 1
+		}
+		if err = tx.Commit(); err != nil {
+			log.Printf("can't commit :( %s", err.Error())
+			return 1
+		}
+		log.Printf("saved %s version %d with %d cards.", version.Cube, version.Version, version.Cards)
+
+	case "list":
+		versions, err := makedraft.ListCubeVersions(tx, *cube)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return 1
+		}
+		for _, version := range versions {
+			fmt.Printf("%d\t%s\t%d cards\t%s\n", version.Version,
+				time.Unix(version.Created, 0).Format("2006-01-02"), version.Cards, version.Note)
+		}
+
+	case "diff":
+		toVersion, err := makedraft.FindCubeVersion(tx, *cube, *to)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return 1
+		}
+		if *from == 0 {
+			*from = toVersion.Version - 1
+		}
+		fromVersion, err := makedraft.FindCubeVersion(tx, *cube, *from)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return 1
+		}
+		diff, err := makedraft.DiffCubeVersions(tx, fromVersion.ID, toVersion.ID)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return 1
+		}
+		fmt.Printf("%s version %d to %d\n", *cube, fromVersion.Version, toVersion.Version)
+		for _, card := range diff.Adds {
+			fmt.Printf("+%d %s (%s)\n", card.Copies, card.Name, card.ID)
+		}
+		for _, card := range diff.Cuts {
+			fmt.Printf("-%d %s (%s)\n", card.Copies, card.Name, card.ID)
+		}
+
+	default:
+		log.Printf("%s", usage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// runImport implements `makedraft import`, which builds a set json file from a
+// CubeCobra CSV or a ratings CSV and a scryfall bulk data file, without going online.
+func runImport(args []string) int {
+	flagSet := flag.NewFlagSet("makedraft import", flag.ContinueOnError)
+
+	scryfallPath := flagSet.String(
+		"scryfall", "default-cards.json",
+		"A scryfall bulk data file, from https://scryfall.com/docs/api/bulk-data.")
+	cubePath := flagSet.String(
+		"cube", "",
+		"A cube list exported from CubeCobra as CSV.")
+	ratingsPath := flagSet.String(
+		"ratings", "",
+		"A CSV of collector numbers and ratings for a retail set. Needs -set-code.")
+	setCode := flagSet.String(
+		"set-code", "",
+		"The set code of the cards in -ratings, like isd.")
+	outPath := flagSet.String(
+		"out", "-",
+		"Where to write the set json file, or - for stdout.")
+	skipUnmatched := flagSet.Bool(
+		"skip-unmatched", false,
+		"If true, write the set file even if some cards couldn't be found, leaving them out.")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+	if (*cubePath == "") == (*ratingsPath == "") {
+		log.Printf("specify exactly one of -cube or -ratings")
+		return 2
+	}
+	if *ratingsPath != "" && *setCode == "" {
+		log.Printf("-ratings needs -set-code")
+		return 2
+	}
+
+	scryfallFile, err := os.Open(*scryfallPath)
+	if err != nil {
+		log.Printf("error opening scryfall bulk data: %s", err.Error())
+		return 1
+	}
+	index, err := makedraft.LoadScryfallBulk(scryfallFile)
+	scryfallFile.Close()
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+	log.Printf("loaded %d printings from %s", index.Len(), *scryfallPath)
+
+	inPath := *cubePath
+	if inPath == "" {
+		inPath = *ratingsPath
+	}
+	in, err := os.Open(inPath)
+	if err != nil {
+		log.Printf("error opening %s: %s", inPath, err.Error())
+		return 1
+	}
+	defer in.Close()
+
+	var cfg makedraft.DraftConfig
+	var problems []makedraft.ImportProblem
+	if *cubePath != "" {
+		cfg, problems, err = makedraft.ImportCubeCobraCSV(in, index)
+	} else {
+		cfg, problems, err = makedraft.ImportRatingsCSV(in, *setCode, index)
+	}
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+
+	skipped := 0
+	for _, problem := range problems {
+		if problem.Skipped {
+			skipped++
+			fmt.Fprintf(os.Stderr, "%s: unmatched, %s\n", inPath, problem)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: warning, %s\n", inPath, problem)
+		}
+	}
+	if skipped > 0 && !*skipUnmatched {
+		log.Printf("%d cards couldn't be matched, not writing a set file. use -skip-unmatched to write it without them.", skipped)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "-" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Printf("error creating %s: %s", *outPath, err.Error())
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(cfg)
+	if err != nil {
+		log.Printf("error writing set file: %s", err.Error())
+		return 1
+	}
+
+	log.Printf("imported %d cards, %d unmatched.", len(cfg.Cards), skipped)
+	return 0
+}
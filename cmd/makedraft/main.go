@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/walkingeyerobot/r38/db"
+	"github.com/walkingeyerobot/r38/drafts"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "validate":
+			os.Exit(runValidate(os.Args[2:]))
+		case "simulate":
+			os.Exit(runSimulate(os.Args[2:]))
+		case "cube":
+			os.Exit(runCube(os.Args[2:]))
+		case "catalog":
+			os.Exit(runCatalog(os.Args[2:]))
+		case "ratings":
+			os.Exit(runRatings(os.Args[2:]))
+		}
+	}
+
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	set := flagSet.String(
+		"set", "sets/cube.json",
+		"A .json file containing relevant set data.")
+	databasePath := flagSet.String(
+		"database", "draft.db",
+		"The sqlite3 database file or postgres connection string to insert to.")
+	databaseDriver := flagSet.String(
+		"database-driver", db.SQLite,
+		"The database driver to use, either sqlite3 or postgres.")
+	simulate := flagSet.Bool(
+		"simulate", false,
+		"If true, won't commit to the database.")
+	dryRun := flagSet.Bool(
+		"dry-run", false,
+		"If true, only generate the draft and write a report, without touching the database.")
+	reportPath := flagSet.String(
+		"report", "",
+		"A file to write a report of the generated draft to, or - for stdout. Defaults to stdout with -dry-run.")
+	roundSets := flagSet.String(
+		"round-sets", "",
+		"A comma separated list of 3 .json set files, one for each round, for a mixed draft. Overrides -set.")
+	chaosSets := flagSet.String(
+		"chaos-sets", "",
+		"A comma separated list of .json set files for a chaos draft, where every pack comes from a random one. Overrides -set.")
+	cube := flagSet.String(
+		"cube", "",
+		"The name of a cube saved in the database with `makedraft cube save`. Overrides -set.")
+	cubeVersion := flagSet.Int64(
+		"cube-version", 0,
+		"The version of -cube to use. Defaults to the latest.")
+	noSuggestions := flagSet.Bool(
+		"no-suggestions", false,
+		"If true, drafters can't ask for pick suggestions in this draft.")
+	spectatorMode := flagSet.String(
+		"spectators", drafts.SpectateLive,
+		"How people who aren't in the draft can watch it: live, hidden, delayed:N (N picks behind) or seat:N (as the player in seat N).")
+	private := flagSet.Bool(
+		"private", false,
+		"If true, the draft isn't listed for people who aren't in it, and joining it needs the invite code this prints.")
+	reportFormat := flagSet.String(
+		"report-format", "json",
+		"The format of the report, either json or html.")
+
+	settings := makedraft.DefaultSettings()
+	settings.RegisterFlags(flagSet)
+
+	flagSet.Parse(os.Args[1:])
+
+	if *set == "" && *roundSets == "" && *chaosSets == "" {
+		log.Printf("you must specify a set json file to continue")
+		return
+	}
+	if *roundSets != "" && *chaosSets != "" {
+		log.Printf("-round-sets and -chaos-sets can't be used together")
+		return
+	}
+	if *cube != "" && (*roundSets != "" || *chaosSets != "") {
+		log.Printf("-cube can't be used with -round-sets or -chaos-sets")
+		return
+	}
+	spectators, err := drafts.ParseSpectatorMode(*spectatorMode)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return
+	}
+
+	// The database is opened up front when the cube comes from it.
+	var tx *sql.Tx
+	beginTx := func() error {
+		if tx != nil {
+			return nil
+		}
+		database, err := openDatabase(*databaseDriver, *databasePath)
+		if err != nil {
+			return err
+		}
+		tx, err = database.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+		if err != nil {
+			return fmt.Errorf("can't create a context: %s", err.Error())
+		}
+		return nil
+	}
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Each set's own flags come first so the command line can override them.
+	var paths []string
+	var mixed makedraft.MixedDraft
+	switch {
+	case *roundSets != "":
+		for _, path := range strings.Split(*roundSets, ",") {
+			index := len(paths)
+			for i, seen := range paths {
+				if seen == path {
+					index = i
+				}
+			}
+			if index == len(paths) {
+				paths = append(paths, path)
+			}
+			mixed.Rounds = append(mixed.Rounds, index)
+		}
+		if len(mixed.Rounds) != 3 {
+			log.Printf("-round-sets needs 3 set json files, one for each round")
+			return
+		}
+	case *chaosSets != "":
+		paths = strings.Split(*chaosSets, ",")
+	case *cube != "":
+	default:
+		paths = []string{*set}
+	}
+	commandLine := settingsArgs(flagSet)
+	for _, path := range paths {
+		draftSet, err := makedraft.LoadDraftSet(path, commandLine)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+		mixed.Sets = append(mixed.Sets, draftSet)
+	}
+	var version makedraft.CubeVersion
+	if *cube != "" {
+		if err := beginTx(); err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+		var cfg makedraft.DraftConfig
+		var err error
+		cfg, version, err = makedraft.LoadCubeVersion(tx, *cube, *cubeVersion)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+		draftSet, err := makedraft.NewDraftSet(*cube, cfg, commandLine)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+		mixed.Sets = append(mixed.Sets, draftSet)
+		log.Printf("using %s version %d.", *cube, version.Version)
+	}
+	settings = mixed.Sets[0].Settings
+
+	log.Printf("generating draft %s.", settings.Name)
+
+	var draft makedraft.GeneratedDraft
+	if len(mixed.Sets) == 1 && len(mixed.Rounds) == 0 {
+		draft, err = makedraft.GenerateDraft(mixed.Sets[0].Config, settings)
+	} else {
+		draft, err = makedraft.GenerateMixedDraft(mixed, settings.Seed)
+	}
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return
+	}
+
+	if *dryRun && *reportPath == "" {
+		*reportPath = "-"
+	}
+	if *reportPath != "" {
+		err = writeReport(makedraft.BuildReport(draft, settings), *reportPath, *reportFormat)
+		if err != nil {
+			log.Printf("error writing report: %s", err.Error())
+			return
+		}
+	}
+	if *dryRun {
+		log.Printf("dry run done. rerun with -seed=%d to create this exact draft.", draft.Seed)
+		return
+	}
+
+	if err = beginTx(); err != nil {
+		log.Printf("%s", err.Error())
+		return
+	}
+
+	if settings.Verbose {
+		log.Printf("inserting into db...")
+	}
+	draftID, err := makedraft.InsertDraft(tx, settings.Name, draft.Packs)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return
+	}
+	if version.ID != 0 {
+		err = drafts.SetCubeVersion(tx, draftID, version.ID)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+	}
+	if *noSuggestions {
+		err = drafts.SetSuggestions(tx, draftID, false)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+	}
+	if spectators.Mode != drafts.SpectateLive {
+		err = drafts.SetSpectators(tx, draftID, spectators)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+	}
+	if *private {
+		invite, err := drafts.SetPrivate(tx, draftID, true)
+		if err != nil {
+			log.Printf("%s", err.Error())
+			return
+		}
+		log.Printf("draft %d is private. players can join at /join/%d?code=%s", draftID, draftID, url.QueryEscape(invite.Code))
+	}
+
+	if *simulate {
+		log.Printf("simulated draft %d, not committing.", draftID)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("can't commit :( %s", err.Error())
+	} else {
+		log.Printf("done! created draft %d.", draftID)
+	}
+}
+
+// writeReport writes a report to path, or to stdout if path is -.
+func writeReport(report makedraft.Report, path string, format string) error {
+	var out io.Writer = os.Stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch format {
+	case "json":
+		return report.WriteJSON(out)
+	case "html":
+		return report.WriteHTML(out)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// settingsArgs returns the settings flags that were given on the command line,
+// so they can be applied over each set's own flags.
+func settingsArgs(flagSet *flag.FlagSet) []string {
+	var settings makedraft.Settings
+	settingsFlags := flag.NewFlagSet("settings", flag.ContinueOnError)
+	settings.RegisterFlags(settingsFlags)
+
+	var args []string
+	flagSet.Visit(func(f *flag.Flag) {
+		if settingsFlags.Lookup(f.Name) != nil {
+			args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value.String()))
+		}
+	})
+	return args
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/walkingeyerobot/r38/db"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// runRatings implements `makedraft ratings`, which fits card ratings to the picks
+// recorded in the database and writes them into a set json file.
+func runRatings(args []string) int {
+	flagSet := flag.NewFlagSet("makedraft ratings", flag.ContinueOnError)
+	set := flagSet.String(
+		"set", "",
+		"The .json set file whose ratings to refit.")
+	databasePath := flagSet.String(
+		"database", "draft.db",
+		"The sqlite3 database file or postgres connection string to use.")
+	databaseDriver := flagSet.String(
+		"database-driver", db.SQLite,
+		"The database driver to use, either sqlite3 or postgres.")
+	drafts := flagSet.String(
+		"drafts", "",
+		"A comma separated list of draft ids to learn from. Defaults to every draft.")
+	minSeen := flagSet.Int(
+		"min-seen", 10,
+		"Only refit cards that were in a pack for at least this many picks.")
+	step := flagSet.Float64(
+		"step", 0.5,
+		"Round ratings to a multiple of this, or 0 not to round them.")
+	iterations := flagSet.Int(
+		"iterations", 1000,
+		"The most iterations to fit strengths with.")
+	outPath := flagSet.String(
+		"out", "-",
+		"Where to write the set json file with the new ratings, or - for stdout.")
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+	if *set == "" {
+		log.Printf("you must specify a -set json file")
+		return 2
+	}
+	var draftIDs []int64
+	for _, field := range strings.Split(*drafts, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			log.Printf("bad draft id %q", field)
+			return 2
+		}
+		draftIDs = append(draftIDs, id)
+	}
+
+	cfg, err := makedraft.LoadDraftConfig(*set)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+
+	database, err := openDatabase(*databaseDriver, *databasePath)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+	tx, err := database.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Printf("can't create a context: %s", err.Error())
+		return 1
+	}
+	defer tx.Rollback()
+
+	choices, cards, skipped, err := makedraft.LoadPickChoices(tx, draftIDs)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+	if skipped > 0 {
+		log.Printf("skipped %d picks of cards that aren't in the catalog. run makedraft catalog to add them.", skipped)
+	}
+	makedraft.FitCardStrengths(choices, cards, *iterations)
+	changes, err := makedraft.ApplyCardStrengths(&cfg, cards, *minSeen, *step)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+
+	for _, change := range changes {
+		fmt.Fprintf(os.Stderr, "%s: %g -> %g, picked %d of %d times seen\n",
+			change.Name, change.Old, change.New, change.Picks, change.Seen)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "-" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Printf("error creating %s: %s", *outPath, err.Error())
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(cfg)
+	if err != nil {
+		log.Printf("error writing set file: %s", err.Error())
+		return 1
+	}
+
+	log.Printf("learned from %d picks, changed %d ratings.", len(choices), len(changes))
+	return 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// runSimulate implements `makedraft simulate`, which generates many drafts in
+// memory and writes statistics about them. It never touches the database.
+func runSimulate(args []string) int {
+	flagSet := flag.NewFlagSet("makedraft simulate", flag.ContinueOnError)
+
+	set := flagSet.String(
+		"set", "sets/cube.json",
+		"A .json file containing relevant set data.")
+	drafts := flagSet.Int(
+		"n", 100,
+		"How many drafts to generate.")
+	outPath := flagSet.String(
+		"out", "-",
+		"Where to write the statistics as json, or - for stdout.")
+
+	settings := makedraft.DefaultSettings()
+	settings.RegisterFlags(flagSet)
+
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := makedraft.LoadDraftConfig(*set)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+
+	// Flags in the set file come first so the command line can override them.
+	jsonFlags, err := cfg.FlagArgs()
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+	if err = flagSet.Parse(append(jsonFlags, args...)); err != nil {
+		return 2
+	}
+
+	log.Printf("simulating %d drafts of %s.", *drafts, *set)
+	simulation, err := makedraft.Simulate(cfg, settings, *drafts)
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "-" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Printf("error creating %s: %s", *outPath, err.Error())
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(simulation)
+	if err != nil {
+		log.Printf("error writing statistics: %s", err.Error())
+		return 1
+	}
+
+	log.Printf("generated %d drafts, %d failed, in %d draft attempts and %d pack attempts. seeds start at %d.",
+		simulation.Drafts, simulation.Failures, simulation.DraftAttempts, simulation.PackAttempts, simulation.Seed)
+	return 0
+}
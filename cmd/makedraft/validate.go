@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// runValidate implements `makedraft validate`, which checks set json files
+// and exits non-zero if any of them has a problem.
+func runValidate(args []string) int {
+	flagSet := flag.NewFlagSet("makedraft validate", flag.ContinueOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "usage: makedraft validate sets/*.json\n")
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return 2
+	}
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range flagSet.Args() {
+		problems, err := validateFile(path)
+		if err != nil {
+			fmt.Printf("%s: %s\n", path, err.Error())
+			status = 1
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", path, problem)
+		}
+		if len(problems) > 0 {
+			status = 1
+			log.Printf("%s has %d problems", path, len(problems))
+		}
+	}
+	return status
+}
+
+// validateFile loads a set json file and validates it. Errors that keep the
+// file from loading at all point at the line and column they happened on.
+func validateFile(path string) ([]makedraft.ValidationProblem, error) {
+	byteValue, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg makedraft.DraftConfig
+	err = json.Unmarshal(byteValue, &cfg)
+	if err != nil {
+		var offset int64
+		switch jsonErr := err.(type) {
+		case *json.SyntaxError:
+			offset = jsonErr.Offset
+		case *json.UnmarshalTypeError:
+			offset = jsonErr.Offset
+		default:
+			return nil, err
+		}
+		line := bytes.Count(byteValue[:offset], []byte("\n")) + 1
+		column := int(offset) - bytes.LastIndexByte(byteValue[:offset], '\n') - 1
+		return nil, fmt.Errorf("line %d column %d: %s", line, column, err.Error())
+	}
+	return makedraft.Validate(cfg), nil
+}
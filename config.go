@@ -0,0 +1,368 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/walkingeyerobot/r38/db"
+	"gopkg.in/yaml.v2"
+)
+
+// Config holds everything the server needs to know at startup. Values come from
+// the defaults below, then the yaml file given by -config or R38_CONFIG, then
+// environment variables, and finally any flags given on the command line.
+type Config struct {
+	Auth            bool          `yaml:"auth"`
+	Dev             bool          `yaml:"dev"`
+	DatabaseDriver  string        `yaml:"database_driver"`
+	Database        string        `yaml:"database"`
+	Listen          string        `yaml:"listen"`
+	BaseURL         string        `yaml:"base_url"`
+	FilterSocket    string        `yaml:"filter_socket"`
+	TemplateDir     string        `yaml:"template_dir"`
+	StaticDir       string        `yaml:"static_dir"`
+	SetsDir         string        `yaml:"sets_dir"`
+	DiscordWebhooks []string      `yaml:"discord_webhooks"`
+	AdminUsers      []int64       `yaml:"admin_users"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	TLSCert         string        `yaml:"tls_cert"`
+	TLSKey          string        `yaml:"tls_key"`
+	MetricsToken    string        `yaml:"metrics_token"`
+}
+
+var config Config
+
+// defaultConfig matches how r38 behaved before it had a config file.
+func defaultConfig() Config {
+	return Config{
+		Auth:            true,
+		DatabaseDriver:  db.SQLite,
+		Database:        "draft.db",
+		Listen:          ":12264",
+		BaseURL:         "http://draft.thefoley.net",
+		FilterSocket:    "./r38.sock",
+		TemplateDir:     ".",
+		StaticDir:       "static",
+		SetsDir:         "sets",
+		AdminUsers:      []int64{1},
+		RequestTimeout:  5 * time.Second,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// LoadConfig builds and validates the server config from the command line arguments.
+func LoadConfig(args []string) (Config, error) {
+	cfg := defaultConfig()
+
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	configFile := flagSet.String("config", os.Getenv("R38_CONFIG"), "A .yaml file containing server configuration.")
+
+	// Flags only override the file and environment when they're actually given,
+	// so remember how to apply each one.
+	setters := make(map[string]func() error)
+	stringFlag := func(name string, dst *string, usage string) {
+		v := flagSet.String(name, *dst, usage)
+		setters[name] = func() error {
+			*dst = *v
+			return nil
+		}
+	}
+	listFlag := func(name string, set func(string) error, usage string) {
+		v := flagSet.String(name, "", usage)
+		setters[name] = func() error {
+			return set(*v)
+		}
+	}
+
+	auth := flagSet.Bool("auth", cfg.Auth, "If false, run without OAuth and treat everyone as user 1.")
+	setters["auth"] = func() error {
+		cfg.Auth = *auth
+		return nil
+	}
+	stringFlag("db-driver", &cfg.DatabaseDriver, "The database driver to use, either sqlite3 or postgres.")
+	stringFlag("db", &cfg.Database, "The sqlite3 file or postgres connection string to use.")
+	stringFlag("listen", &cfg.Listen, "The address to listen on: host:port, unix:/path/to.sock or systemd.")
+	stringFlag("base-url", &cfg.BaseURL, "The public URL of the site, used in notifications.")
+	stringFlag("filter-socket", &cfg.FilterSocket, "The unix socket of the draft filtering backend.")
+	dev := flagSet.Bool("dev", cfg.Dev, "If true, reload templates and static files from disk instead of using the embedded copies.")
+	setters["dev"] = func() error {
+		cfg.Dev = *dev
+		return nil
+	}
+	stringFlag("template-dir", &cfg.TemplateDir, "The directory containing .tmpl files. Only used with -dev.")
+	stringFlag("static-dir", &cfg.StaticDir, "The directory served under /static/. Only used with -dev.")
+	stringFlag("sets-dir", &cfg.SetsDir, "The directory containing set .json files for creating drafts.")
+	listFlag("discord-webhooks", cfg.setDiscordWebhooks, "A comma separated list of discord webhook URLs to notify.")
+	listFlag("admin-users", cfg.setAdminUsers, "A comma separated list of user ids allowed to administer the site.")
+	timeout := flagSet.Duration("request-timeout", cfg.RequestTimeout, "How long a request may take before it's cancelled.")
+	setters["request-timeout"] = func() error {
+		cfg.RequestTimeout = *timeout
+		return nil
+	}
+	shutdownTimeout := flagSet.Duration("shutdown-timeout", cfg.ShutdownTimeout, "How long to wait for requests and notifications to finish when shutting down.")
+	setters["shutdown-timeout"] = func() error {
+		cfg.ShutdownTimeout = *shutdownTimeout
+		return nil
+	}
+	stringFlag("tls-cert", &cfg.TLSCert, "A certificate file to serve TLS with. Requires -tls-key.")
+	stringFlag("tls-key", &cfg.TLSKey, "A private key file to serve TLS with. Requires -tls-cert.")
+	stringFlag("metrics-token", &cfg.MetricsToken, "The bearer token Prometheus must send to read /metrics. /metrics is off without one.")
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if *configFile != "" {
+		err = cfg.loadFile(*configFile)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	err = cfg.loadEnv()
+	if err != nil {
+		return cfg, err
+	}
+
+	flagSet.Visit(func(f *flag.Flag) {
+		if set, ok := setters[f.Name]; ok && err == nil {
+			err = set()
+			if err != nil {
+				err = fmt.Errorf("bad value for -%s: %s", f.Name, err.Error())
+			}
+		}
+	})
+	if err != nil {
+		return cfg, err
+	}
+
+	err = cfg.Validate()
+	return cfg, err
+}
+
+// loadFile reads a yaml config file over the current values.
+func (cfg *Config) loadFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %s", err.Error())
+	}
+	err = yaml.UnmarshalStrict(contents, cfg)
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %s", path, err.Error())
+	}
+	return nil
+}
+
+// loadEnv reads environment overrides over the current values.
+func (cfg *Config) loadEnv() error {
+	if v, ok := os.LookupEnv("R38_AUTH"); ok {
+		auth, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("bad value for R38_AUTH: %s", err.Error())
+		}
+		cfg.Auth = auth
+	}
+	if v, ok := os.LookupEnv("R38_DEV"); ok {
+		dev, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("bad value for R38_DEV: %s", err.Error())
+		}
+		cfg.Dev = dev
+	}
+	if v, ok := os.LookupEnv("R38_DB_DRIVER"); ok {
+		cfg.DatabaseDriver = v
+	}
+	if v, ok := os.LookupEnv("R38_DB"); ok {
+		cfg.Database = v
+	}
+	// R38_PORT predates the config file and only ever held a port.
+	if v, ok := os.LookupEnv("R38_PORT"); ok {
+		cfg.Listen = ":" + v
+	}
+	if v, ok := os.LookupEnv("R38_LISTEN"); ok {
+		cfg.Listen = v
+	}
+	if v, ok := os.LookupEnv("R38_BASE_URL"); ok {
+		cfg.BaseURL = v
+	}
+	if v, ok := os.LookupEnv("R38_SOCK"); ok {
+		cfg.FilterSocket = v
+	}
+	if v, ok := os.LookupEnv("R38_TEMPLATE_DIR"); ok {
+		cfg.TemplateDir = v
+	}
+	if v, ok := os.LookupEnv("R38_STATIC_DIR"); ok {
+		cfg.StaticDir = v
+	}
+	if v, ok := os.LookupEnv("R38_SETS_DIR"); ok {
+		cfg.SetsDir = v
+	}
+	// DISCORD_WEBHOOK_URL also predates the config file.
+	if v, ok := os.LookupEnv("DISCORD_WEBHOOK_URL"); ok {
+		err := cfg.setDiscordWebhooks(v)
+		if err != nil {
+			return fmt.Errorf("bad value for DISCORD_WEBHOOK_URL: %s", err.Error())
+		}
+	}
+	if v, ok := os.LookupEnv("R38_DISCORD_WEBHOOKS"); ok {
+		err := cfg.setDiscordWebhooks(v)
+		if err != nil {
+			return fmt.Errorf("bad value for R38_DISCORD_WEBHOOKS: %s", err.Error())
+		}
+	}
+	if v, ok := os.LookupEnv("R38_ADMIN_USERS"); ok {
+		err := cfg.setAdminUsers(v)
+		if err != nil {
+			return fmt.Errorf("bad value for R38_ADMIN_USERS: %s", err.Error())
+		}
+	}
+	if v, ok := os.LookupEnv("R38_REQUEST_TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("bad value for R38_REQUEST_TIMEOUT: %s", err.Error())
+		}
+		cfg.RequestTimeout = timeout
+	}
+	if v, ok := os.LookupEnv("R38_SHUTDOWN_TIMEOUT"); ok {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("bad value for R38_SHUTDOWN_TIMEOUT: %s", err.Error())
+		}
+		cfg.ShutdownTimeout = timeout
+	}
+	if v, ok := os.LookupEnv("R38_TLS_CERT"); ok {
+		cfg.TLSCert = v
+	}
+	if v, ok := os.LookupEnv("R38_TLS_KEY"); ok {
+		cfg.TLSKey = v
+	}
+	if v, ok := os.LookupEnv("R38_METRICS_TOKEN"); ok {
+		cfg.MetricsToken = v
+	}
+	return nil
+}
+
+// setDiscordWebhooks replaces the webhooks with a comma separated list.
+func (cfg *Config) setDiscordWebhooks(list string) error {
+	cfg.DiscordWebhooks = nil
+	for _, webhook := range strings.Split(list, ",") {
+		webhook = strings.TrimSpace(webhook)
+		if webhook != "" {
+			cfg.DiscordWebhooks = append(cfg.DiscordWebhooks, webhook)
+		}
+	}
+	return nil
+}
+
+// setAdminUsers replaces the admin users with a comma separated list of ids.
+func (cfg *Config) setAdminUsers(list string) error {
+	cfg.AdminUsers = nil
+	for _, id := range strings.Split(list, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		userID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return err
+		}
+		cfg.AdminUsers = append(cfg.AdminUsers, userID)
+	}
+	return nil
+}
+
+// Validate makes sure the config is usable before the server starts.
+func (cfg *Config) Validate() error {
+	var problems []string
+
+	if cfg.DatabaseDriver != db.SQLite && cfg.DatabaseDriver != db.Postgres {
+		problems = append(problems, fmt.Sprintf("database_driver must be %s or %s, not %q", db.SQLite, db.Postgres, cfg.DatabaseDriver))
+	}
+	if cfg.Database == "" {
+		problems = append(problems, "database must be set")
+	}
+	if cfg.Listen == "" {
+		problems = append(problems, "listen must be set")
+	} else if err := validateListen(cfg.Listen); err != nil {
+		problems = append(problems, err.Error())
+	}
+	baseURL, err := url.Parse(cfg.BaseURL)
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		problems = append(problems, fmt.Sprintf("base_url must be an absolute http(s) URL, not %q", cfg.BaseURL))
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.FilterSocket == "" {
+		problems = append(problems, "filter_socket must be set")
+	}
+	// Outside of dev mode the templates and static files are embedded, so the directories don't matter.
+	if cfg.Dev {
+		for _, name := range []string{"index.tmpl", "login.tmpl", "vue.tmpl"} {
+			_, err = os.Stat(filepath.Join(cfg.TemplateDir, name))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("template_dir %q: %s", cfg.TemplateDir, err.Error()))
+			}
+		}
+		info, err := os.Stat(cfg.StaticDir)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("static_dir %q: %s", cfg.StaticDir, err.Error()))
+		} else if !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("static_dir %q is not a directory", cfg.StaticDir))
+		}
+	}
+	for _, webhook := range cfg.DiscordWebhooks {
+		u, err := url.Parse(webhook)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("discord webhook %q must be an absolute https URL", webhook))
+		}
+	}
+	for _, userID := range cfg.AdminUsers {
+		if userID <= 0 {
+			problems = append(problems, fmt.Sprintf("admin user id %d is not valid", userID))
+		}
+	}
+	if cfg.RequestTimeout <= 0 {
+		problems = append(problems, "request_timeout must be positive")
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown_timeout must be positive")
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		problems = append(problems, "tls_cert and tls_key must be set together")
+	}
+	for _, file := range []string{cfg.TLSCert, cfg.TLSKey} {
+		if file == "" {
+			continue
+		}
+		_, err = os.Stat(file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("tls file %q: %s", file, err.Error()))
+		}
+	}
+	if cfg.Auth && len(secretKeyNoOneWillEverGuess) == 0 {
+		problems = append(problems, "SESSION_SECRET must be set when auth is enabled")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// IsAdmin reports whether a user may use admin only features.
+func (cfg *Config) IsAdmin(userID int64) bool {
+	for _, adminID := range cfg.AdminUsers {
+		if adminID == userID {
+			return true
+		}
+	}
+	return false
+}
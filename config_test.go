@@ -0,0 +1,267 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configEnv is every environment variable LoadConfig reads.
+var configEnv = []string{
+	"R38_CONFIG", "R38_AUTH", "R38_DEV", "R38_DB_DRIVER", "R38_DB", "R38_PORT", "R38_LISTEN",
+	"R38_BASE_URL", "R38_SOCK", "R38_TEMPLATE_DIR", "R38_STATIC_DIR", "R38_SETS_DIR",
+	"DISCORD_WEBHOOK_URL", "R38_DISCORD_WEBHOOKS", "R38_ADMIN_USERS", "R38_REQUEST_TIMEOUT",
+	"R38_SHUTDOWN_TIMEOUT", "R38_TLS_CERT", "R38_TLS_KEY", "R38_METRICS_TOKEN",
+}
+
+// setConfigEnv clears the config environment variables, sets env, and returns a func
+// that puts everything back.
+func setConfigEnv(env map[string]string) func() {
+	saved := make(map[string]*string)
+	for _, name := range configEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			saved[name] = &v
+		} else {
+			saved[name] = nil
+		}
+		os.Unsetenv(name)
+	}
+	for name, v := range env {
+		os.Setenv(name, v)
+	}
+	return func() {
+		for name, v := range saved {
+			if v == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *v)
+			}
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "r38config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "r38.yaml")
+	err = ioutil.WriteFile(file, []byte("auth: false\nbase_url: http://file.example\nlisten: \":1111\"\nadmin_users: [5]\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badFile := filepath.Join(dir, "bad.yaml")
+	err = ioutil.WriteFile(badFile, []byte("auth: false\nno_such_setting: 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		check   func(cfg Config) interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			args:  []string{"-auth=false"},
+			check: func(cfg Config) interface{} { return cfg.Listen },
+			want:  ":12264",
+		},
+		{
+			name:  "file over defaults",
+			args:  []string{"-config=" + file},
+			check: func(cfg Config) interface{} { return cfg.BaseURL },
+			want:  "http://file.example",
+		},
+		{
+			name:  "file from R38_CONFIG",
+			env:   map[string]string{"R38_CONFIG": file},
+			check: func(cfg Config) interface{} { return cfg.BaseURL },
+			want:  "http://file.example",
+		},
+		{
+			name:  "environment over file",
+			env:   map[string]string{"R38_BASE_URL": "http://env.example"},
+			args:  []string{"-config=" + file},
+			check: func(cfg Config) interface{} { return cfg.BaseURL },
+			want:  "http://env.example",
+		},
+		{
+			name:  "flag over environment",
+			env:   map[string]string{"R38_BASE_URL": "http://env.example"},
+			args:  []string{"-config=" + file, "-base-url=http://flag.example"},
+			check: func(cfg Config) interface{} { return cfg.BaseURL },
+			want:  "http://flag.example",
+		},
+		{
+			name:  "flag left at its default doesn't override",
+			env:   map[string]string{"R38_LISTEN": ":3333"},
+			args:  []string{"-auth=false"},
+			check: func(cfg Config) interface{} { return cfg.Listen },
+			want:  ":3333",
+		},
+		{
+			name:  "R38_PORT is a port",
+			env:   map[string]string{"R38_PORT": "2222"},
+			args:  []string{"-config=" + file},
+			check: func(cfg Config) interface{} { return cfg.Listen },
+			want:  ":2222",
+		},
+		{
+			name:  "R38_LISTEN over R38_PORT",
+			env:   map[string]string{"R38_PORT": "2222", "R38_LISTEN": "unix:/tmp/r38.sock"},
+			args:  []string{"-auth=false"},
+			check: func(cfg Config) interface{} { return cfg.Listen },
+			want:  "unix:/tmp/r38.sock",
+		},
+		{
+			name:  "list flag replaces the file's list",
+			args:  []string{"-config=" + file, "-admin-users=2, 3"},
+			check: func(cfg Config) interface{} { return cfg.AdminUsers },
+			want:  []int64{2, 3},
+		},
+		{
+			name:  "webhooks from the old variable",
+			env:   map[string]string{"DISCORD_WEBHOOK_URL": "https://discord.example/a"},
+			args:  []string{"-auth=false"},
+			check: func(cfg Config) interface{} { return cfg.DiscordWebhooks },
+			want:  []string{"https://discord.example/a"},
+		},
+		{
+			name:  "duration from the environment",
+			env:   map[string]string{"R38_REQUEST_TIMEOUT": "9s"},
+			args:  []string{"-auth=false"},
+			check: func(cfg Config) interface{} { return cfg.RequestTimeout },
+			want:  9 * time.Second,
+		},
+		{
+			name:  "base url loses its trailing slash",
+			args:  []string{"-auth=false", "-base-url=http://flag.example/"},
+			check: func(cfg Config) interface{} { return cfg.BaseURL },
+			want:  "http://flag.example",
+		},
+		{
+			name:    "bad environment value",
+			env:     map[string]string{"R38_AUTH": "maybe"},
+			wantErr: "bad value for R38_AUTH",
+		},
+		{
+			name:    "bad list flag",
+			args:    []string{"-auth=false", "-admin-users=one"},
+			wantErr: "bad value for -admin-users",
+		},
+		{
+			name:    "unknown setting in the file",
+			args:    []string{"-config=" + badFile},
+			wantErr: "error parsing config file",
+		},
+		{
+			name:    "missing file",
+			args:    []string{"-config=" + filepath.Join(dir, "missing.yaml")},
+			wantErr: "error reading config file",
+		},
+		{
+			name:    "invalid result",
+			args:    []string{"-auth=false", "-listen="},
+			wantErr: "listen must be set",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer setConfigEnv(test.env)()
+			cfg, err := LoadConfig(test.args)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("LoadConfig(%q) error = %v, want %q", test.args, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig(%q) error = %v", test.args, err)
+			}
+			if got := test.check(cfg); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("LoadConfig(%q) = %v, want %v", test.args, got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(cfg *Config)
+		wantErr string
+	}{
+		{"defaults", func(cfg *Config) {}, ""},
+		{"postgres", func(cfg *Config) { cfg.DatabaseDriver = "postgres" }, ""},
+		{"unknown driver", func(cfg *Config) { cfg.DatabaseDriver = "mysql" }, "database_driver must be"},
+		{"no database", func(cfg *Config) { cfg.Database = "" }, "database must be set"},
+		{"no listen", func(cfg *Config) { cfg.Listen = "" }, "listen must be set"},
+		{"bad listen", func(cfg *Config) { cfg.Listen = "12264" }, "must be host:port"},
+		{"unix socket without a path", func(cfg *Config) { cfg.Listen = "unix:" }, "missing a socket path"},
+		{"systemd listen", func(cfg *Config) { cfg.Listen = "systemd" }, ""},
+		{"relative base url", func(cfg *Config) { cfg.BaseURL = "draft.example" }, "base_url must be"},
+		{"no filter socket", func(cfg *Config) { cfg.FilterSocket = "" }, "filter_socket must be set"},
+		{"missing sets dir", func(cfg *Config) { cfg.SetsDir = "/no/such/sets" }, ""},
+		{"http webhook", func(cfg *Config) { cfg.DiscordWebhooks = []string{"http://discord.example/a"} }, "must be an absolute https URL"},
+		{"bad admin id", func(cfg *Config) { cfg.AdminUsers = []int64{0} }, "admin user id 0 is not valid"},
+		{"no request timeout", func(cfg *Config) { cfg.RequestTimeout = 0 }, "request_timeout must be positive"},
+		{"no shutdown timeout", func(cfg *Config) { cfg.ShutdownTimeout = -time.Second }, "shutdown_timeout must be positive"},
+		{"cert without key", func(cfg *Config) { cfg.TLSCert = "/no/such/cert" }, "must be set together"},
+		{"missing tls files", func(cfg *Config) { cfg.TLSCert, cfg.TLSKey = "/no/such/cert", "/no/such/key" }, "tls file"},
+		{"missing templates in dev mode", func(cfg *Config) { cfg.Dev, cfg.TemplateDir = true, "/no/such/templates" }, "template_dir"},
+		{"several problems", func(cfg *Config) { cfg.Database, cfg.FilterSocket = "", "" }, "database must be set\n  filter_socket must be set"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Auth = false
+			test.change(&cfg)
+			err := cfg.Validate()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigSessionSecret(t *testing.T) {
+	saved := secretKeyNoOneWillEverGuess
+	defer func() { secretKeyNoOneWillEverGuess = saved }()
+
+	tests := []struct {
+		name    string
+		auth    bool
+		secret  string
+		wantErr bool
+	}{
+		{"auth with a secret", true, "secret", false},
+		{"auth without a secret", true, "", true},
+		{"no auth without a secret", false, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secretKeyNoOneWillEverGuess = []byte(test.secret)
+			cfg := defaultConfig()
+			cfg.Auth = test.auth
+			err := cfg.Validate()
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/walkingeyerobot/r38/drafts"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// ServeAPICubeVersions serves the /api/cubeversions/{cube} endpoint, which lists
+// every saved version of a cube.
+func ServeAPICubeVersions(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	cube := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/cubeversions/"), "/")
+	if !makedraft.CubeNameRegexp.MatchString(cube) {
+		return fmt.Errorf("bad api url")
+	}
+
+	versions, err := makedraft.ListCubeVersions(tx, cube)
+	if err != nil {
+		return err
+	}
+
+	json.NewEncoder(w).Encode(versions)
+	return nil
+}
+
+// ServeAPICubeDiff serves the /api/cubediff endpoint, which lists the cards added and cut
+// between two versions of a cube. The versions are given either as ?cube=name&from=1&to=2,
+// where to defaults to the latest version and from to the one before it, or as
+// ?fromDraft=12&toDraft=15 to compare the cube versions two drafts were made from.
+func ServeAPICubeDiff(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	q := r.URL.Query()
+	param := func(name string) (int64, error) {
+		if q.Get(name) == "" {
+			return 0, nil
+		}
+		value, err := strconv.ParseInt(q.Get(name), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad %s: %s", name, err.Error())
+		}
+		return value, nil
+	}
+
+	var fromID, toID int64
+	if q.Get("fromDraft") != "" || q.Get("toDraft") != "" {
+		fromDraft, err := param("fromDraft")
+		if err != nil {
+			return err
+		}
+		toDraft, err := param("toDraft")
+		if err != nil {
+			return err
+		}
+		fromID, err = drafts.CubeVersion(tx, fromDraft)
+		if err != nil {
+			return err
+		}
+		toID, err = drafts.CubeVersion(tx, toDraft)
+		if err != nil {
+			return err
+		}
+	} else {
+		from, err := param("from")
+		if err != nil {
+			return err
+		}
+		to, err := param("to")
+		if err != nil {
+			return err
+		}
+		toVersion, err := makedraft.FindCubeVersion(tx, q.Get("cube"), to)
+		if err != nil {
+			return err
+		}
+		if from == 0 {
+			if toVersion.Version == 1 {
+				return fmt.Errorf("cube %s has no earlier version", toVersion.Cube)
+			}
+			from = toVersion.Version - 1
+		}
+		fromVersion, err := makedraft.FindCubeVersion(tx, q.Get("cube"), from)
+		if err != nil {
+			return err
+		}
+		fromID, toID = fromVersion.ID, toVersion.ID
+	}
+
+	diff, err := makedraft.DiffCubeVersions(tx, fromID, toID)
+	if err != nil {
+		return err
+	}
+
+	json.NewEncoder(w).Encode(diff)
+	return nil
+}
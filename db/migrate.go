@@ -0,0 +1,181 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// migration is a single schema change, written once for each dialect.
+type migration struct {
+	ID       int64
+	Name     string
+	SQLite   string
+	Postgres string
+}
+
+// migrations must only ever be appended to.
+var migrations = []migration{
+	{
+		ID:   1,
+		Name: "initial schema",
+		SQLite: `
+CREATE TABLE IF NOT EXISTS users (id integer primary key autoincrement, discord_id text unique, discord_name text, picture text);
+CREATE TABLE IF NOT EXISTS users_old (id integer primary key autoincrement, google_id text unique, email text, picture text, slack string, discord string, webhook string);
+CREATE TABLE IF NOT EXISTS seats (id integer primary key autoincrement, position number, user number, draft number, round number default 1);
+CREATE TABLE IF NOT EXISTS packs (id integer primary key autoincrement, seat number, modified number, round number, original_seat number);
+CREATE TABLE IF NOT EXISTS cards (id integer primary key autoincrement, pack number, edition text, number text, tags text, name text, faceup number default false, original_pack number, cmc number, type text, color text, modified number default 0, mtgo string, data text);
+CREATE TABLE IF NOT EXISTS drafts (id integer primary key autoincrement, name text);
+CREATE TABLE IF NOT EXISTS revealed (id integer primary key autoincrement, draft number, message text);
+CREATE TABLE IF NOT EXISTS events (id integer primary key autoincrement, draft number, user number, announcement text, card1 number, card2 number, modified number, round number, position number);
+CREATE VIEW IF NOT EXISTS v_packs AS select packs.*, count(cards.id) as count from packs left join cards on packs.id = cards.pack group by packs.id;
+`,
+		Postgres: `
+CREATE TABLE IF NOT EXISTS users (id bigserial primary key, discord_id text unique, discord_name text, picture text);
+CREATE TABLE IF NOT EXISTS users_old (id bigserial primary key, google_id text unique, email text, picture text, slack text, discord text, webhook text);
+CREATE TABLE IF NOT EXISTS seats (id bigserial primary key, position bigint, "user" bigint, draft bigint, round bigint default 1);
+CREATE TABLE IF NOT EXISTS packs (id bigserial primary key, seat bigint, modified bigint, round bigint, original_seat bigint);
+CREATE TABLE IF NOT EXISTS cards (id bigserial primary key, pack bigint, edition text, number text, tags text, name text, faceup boolean default false, original_pack bigint, cmc bigint, type text, color text, modified bigint default 0, mtgo text, data text);
+CREATE TABLE IF NOT EXISTS drafts (id bigserial primary key, name text);
+CREATE TABLE IF NOT EXISTS revealed (id bigserial primary key, draft bigint, message text);
+CREATE TABLE IF NOT EXISTS events (id bigserial primary key, draft bigint, "user" bigint, announcement text, card1 bigint, card2 bigint, modified bigint, round bigint, position bigint);
+CREATE OR REPLACE VIEW v_packs AS select packs.*, count(cards.id) as count from packs left join cards on packs.id = cards.pack group by packs.id;
+`,
+	},
+	{
+		ID:   2,
+		Name: "cube versions",
+		SQLite: `
+CREATE TABLE IF NOT EXISTS cubes (id integer primary key autoincrement, name text unique);
+CREATE TABLE IF NOT EXISTS cube_versions (id integer primary key autoincrement, cube number, version number, note text, created number, config text);
+CREATE TABLE IF NOT EXISTS cube_version_cards (id integer primary key autoincrement, cube_version number, position number, card_id text, name text, card text);
+CREATE INDEX IF NOT EXISTS cube_version_cards_version ON cube_version_cards (cube_version);
+ALTER TABLE drafts ADD COLUMN cube_version number;
+`,
+		Postgres: `
+CREATE TABLE IF NOT EXISTS cubes (id bigserial primary key, name text unique);
+CREATE TABLE IF NOT EXISTS cube_versions (id bigserial primary key, cube bigint, version bigint, note text, created bigint, config text);
+CREATE TABLE IF NOT EXISTS cube_version_cards (id bigserial primary key, cube_version bigint, position bigint, card_id text, name text, card text);
+CREATE INDEX IF NOT EXISTS cube_version_cards_version ON cube_version_cards (cube_version);
+ALTER TABLE drafts ADD COLUMN IF NOT EXISTS cube_version bigint;
+`,
+	},
+	{
+		ID:   3,
+		Name: "card catalog",
+		SQLite: `
+CREATE TABLE IF NOT EXISTS catalog (id integer primary key autoincrement, scryfall_id text unique, name text, edition text, number text, cmc real, type text, color text, color_identity text, rarity text, data text);
+CREATE INDEX IF NOT EXISTS catalog_edition_number ON catalog (edition, number);
+ALTER TABLE cards ADD COLUMN catalog number;
+ALTER TABLE cards ADD COLUMN foil number default false;
+ALTER TABLE cards ADD COLUMN details text;
+`,
+		Postgres: `
+CREATE TABLE IF NOT EXISTS catalog (id bigserial primary key, scryfall_id text unique, name text, edition text, number text, cmc double precision, type text, color text, color_identity text, rarity text, data text);
+CREATE INDEX IF NOT EXISTS catalog_edition_number ON catalog (edition, number);
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS catalog bigint;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS foil boolean default false;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS details text;
+`,
+	},
+	{
+		ID:   4,
+		Name: "draft suggestions",
+		SQLite: `
+ALTER TABLE drafts ADD COLUMN suggestions number default true;
+`,
+		Postgres: `
+ALTER TABLE drafts ADD COLUMN IF NOT EXISTS suggestions boolean default true;
+`,
+	},
+	{
+		ID:   5,
+		Name: "draft spectators",
+		SQLite: `
+ALTER TABLE drafts ADD COLUMN spectators text default 'live';
+`,
+		Postgres: `
+ALTER TABLE drafts ADD COLUMN IF NOT EXISTS spectators text default 'live';
+`,
+	},
+	{
+		ID:   6,
+		Name: "private drafts",
+		SQLite: `
+ALTER TABLE drafts ADD COLUMN private number default false;
+ALTER TABLE drafts ADD COLUMN invite_code text;
+`,
+		Postgres: `
+ALTER TABLE drafts ADD COLUMN IF NOT EXISTS private boolean default false;
+ALTER TABLE drafts ADD COLUMN IF NOT EXISTS invite_code text;
+`,
+	},
+	{
+		ID:   7,
+		Name: "unique cube version numbers",
+		SQLite: `
+CREATE UNIQUE INDEX IF NOT EXISTS cube_versions_cube_version ON cube_versions (cube, version);
+`,
+		Postgres: `
+CREATE UNIQUE INDEX IF NOT EXISTS cube_versions_cube_version ON cube_versions (cube, version);
+`,
+	},
+}
+
+// Migrate brings the schema up to date. It is safe to run against a database
+// that was created by hand before migrations existed.
+func Migrate(database *sql.DB, driver string) error {
+	_, err := database.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (id integer primary key, name text)`)
+	if err != nil {
+		return fmt.Errorf("can't create schema_migrations: %s", err.Error())
+	}
+
+	for _, m := range migrations {
+		var applied int64
+		row := database.QueryRow(`select count(1) from schema_migrations where id = ?`, m.ID)
+		err = row.Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+
+		var script string
+		switch driver {
+		case SQLite:
+			script = m.SQLite
+		case Postgres:
+			script = m.Postgres
+		default:
+			return fmt.Errorf("unknown database driver %q", driver)
+		}
+
+		log.Printf("applying migration %d: %s", m.ID, m.Name)
+		tx, err := database.Begin()
+		if err != nil {
+			return err
+		}
+		for _, statement := range strings.Split(script, ";\n") {
+			if strings.TrimSpace(statement) == "" {
+				continue
+			}
+			_, err = tx.Exec(statement)
+			if err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d failed: %s", m.ID, err.Error())
+			}
+		}
+		_, err = tx.Exec(`insert into schema_migrations (id, name) values (?, ?)`, m.ID, m.Name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// pgConnector wraps a lib/pq connector so that every connection it hands out
+// understands the SQLite flavored SQL the rest of r38 is written in.
+type pgConnector struct {
+	connector driver.Connector
+}
+
+// pgConn rewrites queries on their way to Postgres.
+type pgConn struct {
+	driver.Conn
+}
+
+// pgInsertStmt is a prepared INSERT ... RETURNING id that still behaves like an Exec.
+type pgInsertStmt struct {
+	driver.Stmt
+}
+
+// insertResult reports the id returned by INSERT ... RETURNING id.
+type insertResult struct {
+	lastID       int64
+	rowsAffected int64
+}
+
+// Connect opens a new wrapped connection.
+func (c *pgConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pgConn{conn}, nil
+}
+
+// Driver returns the underlying lib/pq driver.
+func (c *pgConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// Prepare prepares a rewritten statement.
+func (c *pgConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext prepares a rewritten statement.
+func (c *pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	insert := isInsert(query)
+	if insert {
+		query = returningID(query)
+	}
+	query = rebind(query)
+
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if insert {
+		return &pgInsertStmt{stmt}, nil
+	}
+	return stmt, nil
+}
+
+// BeginTx starts a transaction, honoring read only and isolation options.
+func (c *pgConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// QueryContext runs a rewritten query.
+func (c *pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return queryer.QueryContext(ctx, rebind(query), args)
+}
+
+// ExecContext runs a rewritten statement. Inserts are run as queries so that
+// the new row's id can be reported through LastInsertId.
+func (c *pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if isInsert(query) {
+		rows, err := c.QueryContext(ctx, returningID(query), args)
+		if err != nil {
+			return nil, err
+		}
+		return readInsertResult(rows)
+	}
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, rebind(query), args)
+}
+
+// Ping checks that the connection is still alive.
+func (c *pgConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession is called before a pooled connection is reused.
+func (c *pgConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// Exec runs the prepared INSERT ... RETURNING id.
+func (s *pgInsertStmt) Exec(args []driver.Value) (driver.Result, error) {
+	rows, err := s.Stmt.Query(args)
+	if err != nil {
+		return nil, err
+	}
+	return readInsertResult(rows)
+}
+
+// LastInsertId returns the id of the last inserted row.
+func (r insertResult) LastInsertId() (int64, error) {
+	return r.lastID, nil
+}
+
+// RowsAffected returns the number of inserted rows.
+func (r insertResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// readInsertResult drains the rows of an INSERT ... RETURNING id.
+func readInsertResult(rows driver.Rows) (driver.Result, error) {
+	defer rows.Close()
+	var result insertResult
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if id, ok := dest[0].(int64); ok {
+			result.lastID = id
+		}
+		result.rowsAffected++
+	}
+	return result, nil
+}
+
+// isInsert reports whether query is an INSERT that doesn't already return anything.
+func isInsert(query string) bool {
+	lower := strings.ToLower(strings.TrimSpace(query))
+	return strings.HasPrefix(lower, "insert") && !strings.Contains(lower, "returning")
+}
+
+// returningID turns an INSERT into INSERT ... RETURNING id.
+func returningID(query string) string {
+	return strings.TrimRight(strings.TrimSpace(query), ";") + " RETURNING id"
+}
+
+// rebind replaces ? placeholders with Postgres style $1, $2, ... placeholders.
+// Question marks inside quoted strings and identifiers are left alone.
+func rebind(query string) string {
+	var b strings.Builder
+	var quote rune
+	n := 0
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$")
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package db
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no placeholders", `select id from drafts`, `select id from drafts`},
+		{"one placeholder", `select id from drafts where id = ?`, `select id from drafts where id = $1`},
+		{"several placeholders", `UPDATE seats SET "user" = ? WHERE draft = ? AND position = ?`, `UPDATE seats SET "user" = $1 WHERE draft = $2 AND position = $3`},
+		{"question mark in a string", `select '?' from drafts where id = ?`, `select '?' from drafts where id = $1`},
+		{"question mark in an identifier", `select "a?b" from drafts where id = ?`, `select "a?b" from drafts where id = $1`},
+		{"double quote inside a string", `select 'say "hi?"' where id = ?`, `select 'say "hi?"' where id = $1`},
+		{"single quote inside an identifier", `select "it's?" where id = ?`, `select "it's?" where id = $1`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := rebind(test.query)
+			if got != test.want {
+				t.Errorf("rebind(%q) = %q, want %q", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsInsert(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  bool
+	}{
+		{"insert", `INSERT INTO drafts (name) VALUES (?)`, true},
+		{"lower case with whitespace", "\n\t  insert into drafts (name) values (?)", true},
+		{"already returning", `INSERT INTO drafts (name) VALUES (?) RETURNING id`, false},
+		{"select", `select id from drafts`, false},
+		{"update", `UPDATE drafts SET name = ?`, false},
+		{"insert later in the query", `select 1 where 'insert' = ?`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := isInsert(test.query)
+			if got != test.want {
+				t.Errorf("isInsert(%q) = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReturningID(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"plain", `INSERT INTO drafts (name) VALUES (?)`, `INSERT INTO drafts (name) VALUES (?) RETURNING id`},
+		{"trailing semicolon", `INSERT INTO drafts (name) VALUES (?);`, `INSERT INTO drafts (name) VALUES (?) RETURNING id`},
+		{"surrounding whitespace", "\n  INSERT INTO drafts (name) VALUES (?)  \n", `INSERT INTO drafts (name) VALUES (?) RETURNING id`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := returningID(test.query)
+			if got != test.want {
+				t.Errorf("returningID(%q) = %q, want %q", test.query, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package drafts
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SetCubeVersion records which cube version a draft's packs came from.
+func SetCubeVersion(tx *sql.Tx, draftID int64, versionID int64) error {
+	_, err := tx.Exec(`UPDATE drafts SET cube_version = ? WHERE id = ?`, versionID, draftID)
+	if err != nil {
+		return fmt.Errorf("error linking draft to cube version: %s", err.Error())
+	}
+	return nil
+}
+
+// CubeVersion returns the id of the cube version a draft's packs came from.
+func CubeVersion(tx *sql.Tx, draftID int64) (int64, error) {
+	var versionID sql.NullInt64
+	err := tx.QueryRow(`select cube_version from drafts where id = ?`, draftID).Scan(&versionID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no draft %d", draftID)
+	} else if err != nil {
+		return 0, fmt.Errorf("error getting draft %d: %s", draftID, err.Error())
+	}
+	if !versionID.Valid {
+		return 0, fmt.Errorf("draft %d wasn't made from a cube version", draftID)
+	}
+	return versionID.Int64, nil
+}
@@ -0,0 +1,101 @@
+package drafts
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+)
+
+// Invite is whether a draft is private, and if it is, the code players need to
+// join it. A private draft with no Code can't be joined until a new code is made.
+type Invite struct {
+	DraftID int64  `json:"draftId"`
+	Private bool   `json:"private"`
+	Code    string `json:"code,omitempty"`
+}
+
+// newInviteCode makes a random invite code that's safe to put in a url.
+func newInviteCode() (string, error) {
+	b := make([]byte, 9)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("can't make an invite code: %s", err.Error())
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// SetPrivate makes a draft private with a new invite code, or public again.
+func SetPrivate(tx *sql.Tx, draftID int64, private bool) (Invite, error) {
+	invite := Invite{DraftID: draftID, Private: private}
+	var code sql.NullString
+	if private {
+		var err error
+		invite.Code, err = newInviteCode()
+		if err != nil {
+			return invite, err
+		}
+		code = sql.NullString{String: invite.Code, Valid: true}
+	}
+	res, err := tx.Exec(`UPDATE drafts SET private = ?, invite_code = ? WHERE id = ?`, private, code, draftID)
+	if err != nil {
+		return invite, fmt.Errorf("error setting draft %d private: %s", draftID, err.Error())
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return invite, fmt.Errorf("no draft %d", draftID)
+	}
+	return invite, nil
+}
+
+// RevokeInviteCode stops a private draft's invite code from working, without making
+// a new one.
+func RevokeInviteCode(tx *sql.Tx, draftID int64) (Invite, error) {
+	invite, err := GetInvite(tx, draftID)
+	if err != nil {
+		return invite, err
+	}
+	if !invite.Private {
+		return invite, fmt.Errorf("draft %d isn't private", draftID)
+	}
+	_, err = tx.Exec(`UPDATE drafts SET invite_code = null WHERE id = ?`, draftID)
+	if err != nil {
+		return invite, fmt.Errorf("error revoking invite code for draft %d: %s", draftID, err.Error())
+	}
+	invite.Code = ""
+	return invite, nil
+}
+
+// GetInvite returns whether a draft is private and its invite code.
+func GetInvite(tx *sql.Tx, draftID int64) (Invite, error) {
+	invite := Invite{DraftID: draftID}
+	var private sql.NullBool
+	var code sql.NullString
+	err := tx.QueryRow(`select private, invite_code from drafts where id = ?`, draftID).Scan(&private, &code)
+	if err == sql.ErrNoRows {
+		return invite, fmt.Errorf("no draft %d", draftID)
+	} else if err != nil {
+		return invite, fmt.Errorf("error getting draft %d: %s", draftID, err.Error())
+	}
+	invite.Private = private.Valid && private.Bool
+	invite.Code = code.String
+	return invite, nil
+}
+
+// CheckInviteCode returns an error unless code lets a player join the draft. Public
+// drafts don't need a code.
+func (invite Invite) CheckInviteCode(code string) error {
+	if !invite.Private {
+		return nil
+	}
+	if invite.Code == "" {
+		return fmt.Errorf("draft %d is private and isn't taking new players", invite.DraftID)
+	}
+	if code == "" {
+		return fmt.Errorf("draft %d is private and needs an invite code", invite.DraftID)
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(invite.Code)) != 1 {
+		return fmt.Errorf("wrong invite code for draft %d", invite.DraftID)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package drafts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckInviteCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		invite  Invite
+		code    string
+		wantErr string
+	}{
+		{"public", Invite{DraftID: 1}, "", ""},
+		{"public ignores a code", Invite{DraftID: 1}, "anything", ""},
+		{"right code", Invite{DraftID: 1, Private: true, Code: "abc-123_"}, "abc-123_", ""},
+		{"revoked code", Invite{DraftID: 1, Private: true}, "abc-123_", "draft 1 is private and isn't taking new players"},
+		{"revoked code and no code", Invite{DraftID: 1, Private: true}, "", "isn't taking new players"},
+		{"no code", Invite{DraftID: 1, Private: true, Code: "abc-123_"}, "", "draft 1 is private and needs an invite code"},
+		{"wrong code", Invite{DraftID: 1, Private: true, Code: "abc-123_"}, "abc-123-", "wrong invite code for draft 1"},
+		{"prefix of the code", Invite{DraftID: 1, Private: true, Code: "abc-123_"}, "abc", "wrong invite code"},
+		{"code is case sensitive", Invite{DraftID: 1, Private: true, Code: "abc-123_"}, "ABC-123_", "wrong invite code"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.invite.CheckInviteCode(test.code)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckInviteCode(%q) error = %v", test.code, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("CheckInviteCode(%q) error = %v, want %q", test.code, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInviteCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := newInviteCode()
+		if err != nil {
+			t.Fatalf("newInviteCode() error = %v", err)
+		}
+		if len(code) != 12 || strings.ContainsAny(code, "+/=") {
+			t.Errorf("newInviteCode() = %q, want 12 url safe characters", code)
+		}
+		if seen[code] {
+			t.Errorf("newInviteCode() made %q twice", code)
+		}
+		seen[code] = true
+	}
+}
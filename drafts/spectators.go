@@ -0,0 +1,106 @@
+// Package drafts reads and writes the per-draft settings that the server and the
+// makedraft command share, like who can watch or join a draft.
+package drafts
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The ways people who aren't in a draft can watch it while it's running. Once every
+// seat has finished, everyone can see the whole draft.
+const (
+	// SpectateLive shows spectators every pick as it happens.
+	SpectateLive = "live"
+	// SpectateHidden shows spectators nothing until the draft is over.
+	SpectateHidden = "hidden"
+	// SpectateDelayed shows spectators the draft as it was Delay picks before the
+	// slowest seat's latest pick.
+	SpectateDelayed = "delayed"
+	// SpectateSeat shows spectators only what the player in Seat can see.
+	SpectateSeat = "seat"
+)
+
+// SpectatorMode is how a draft can be watched by people who aren't in it. It's written
+// as live, hidden, delayed:N or seat:N.
+type SpectatorMode struct {
+	Mode  string
+	Delay int64
+	Seat  int64
+}
+
+// ParseSpectatorMode reads a SpectatorMode from its String form. An empty string is live.
+func ParseSpectatorMode(s string) (SpectatorMode, error) {
+	parts := strings.SplitN(s, ":", 2)
+	name, value := parts[0], ""
+	if len(parts) > 1 {
+		value = parts[1]
+	}
+	switch name {
+	case "", SpectateLive, SpectateHidden:
+		if len(parts) > 1 {
+			return SpectatorMode{}, fmt.Errorf("spectator mode %q doesn't take a value", name)
+		}
+		if name == "" {
+			name = SpectateLive
+		}
+		return SpectatorMode{Mode: name}, nil
+	case SpectateDelayed, SpectateSeat:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return SpectatorMode{}, fmt.Errorf("spectator mode %q needs a number, like %s:3", s, name)
+		}
+		if name == SpectateDelayed {
+			if n < 1 {
+				return SpectatorMode{}, fmt.Errorf("spectators must be delayed by at least 1 pick")
+			}
+			return SpectatorMode{Mode: name, Delay: n}, nil
+		}
+		if n < 0 || n > 7 {
+			return SpectatorMode{}, fmt.Errorf("there's no seat %d, seats are 0 to 7", n)
+		}
+		return SpectatorMode{Mode: name, Seat: n}, nil
+	default:
+		return SpectatorMode{}, fmt.Errorf("unknown spectator mode %q, use live, hidden, delayed:N or seat:N", s)
+	}
+}
+
+// String writes a SpectatorMode the way ParseSpectatorMode reads it.
+func (m SpectatorMode) String() string {
+	switch m.Mode {
+	case SpectateDelayed:
+		return fmt.Sprintf("%s:%d", m.Mode, m.Delay)
+	case SpectateSeat:
+		return fmt.Sprintf("%s:%d", m.Mode, m.Seat)
+	case "":
+		return SpectateLive
+	default:
+		return m.Mode
+	}
+}
+
+// SetSpectators sets how spectators can watch a draft.
+func SetSpectators(tx *sql.Tx, draftID int64, mode SpectatorMode) error {
+	res, err := tx.Exec(`UPDATE drafts SET spectators = ? WHERE id = ?`, mode.String(), draftID)
+	if err != nil {
+		return fmt.Errorf("error setting spectators for draft %d: %s", draftID, err.Error())
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no draft %d", draftID)
+	}
+	return nil
+}
+
+// Spectators returns how spectators can watch a draft.
+func Spectators(tx *sql.Tx, draftID int64) (SpectatorMode, error) {
+	var mode sql.NullString
+	err := tx.QueryRow(`select spectators from drafts where id = ?`, draftID).Scan(&mode)
+	if err == sql.ErrNoRows {
+		return SpectatorMode{}, fmt.Errorf("no draft %d", draftID)
+	} else if err != nil {
+		return SpectatorMode{}, fmt.Errorf("error getting draft %d: %s", draftID, err.Error())
+	}
+	return ParseSpectatorMode(mode.String)
+}
@@ -0,0 +1,30 @@
+package drafts
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// SetSuggestions turns pick suggestions on or off for a draft.
+func SetSuggestions(tx *sql.Tx, draftID int64, enabled bool) error {
+	res, err := tx.Exec(`UPDATE drafts SET suggestions = ? WHERE id = ?`, enabled, draftID)
+	if err != nil {
+		return fmt.Errorf("error setting suggestions for draft %d: %s", draftID, err.Error())
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("no draft %d", draftID)
+	}
+	return nil
+}
+
+// Suggestions returns whether pick suggestions are on for a draft.
+func Suggestions(tx *sql.Tx, draftID int64) (bool, error) {
+	var enabled sql.NullBool
+	err := tx.QueryRow(`select suggestions from drafts where id = ?`, draftID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("no draft %d", draftID)
+	} else if err != nil {
+		return false, fmt.Errorf("error getting draft %d: %s", draftID, err.Error())
+	}
+	return !enabled.Valid || enabled.Bool, nil
+}
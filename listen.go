@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/activation"
+)
+
+const (
+	listenSystemd    = "systemd"
+	listenUnixPrefix = "unix:"
+)
+
+// Listen opens the listener described by the listen setting.
+// "systemd" uses the first socket handed over by systemd socket activation,
+// "unix:/path/to/r38.sock" listens on a unix socket, and anything else is
+// treated as a TCP address like ":12264".
+func Listen(address string) (net.Listener, error) {
+	if address == listenSystemd {
+		listeners, err := activation.Listeners()
+		if err != nil {
+			return nil, fmt.Errorf("error getting systemd sockets: %s", err.Error())
+		}
+		if len(listeners) == 0 || listeners[0] == nil {
+			return nil, fmt.Errorf("no socket was passed in by systemd")
+		}
+		for _, extra := range listeners[1:] {
+			if extra != nil {
+				extra.Close()
+			}
+		}
+		return listeners[0], nil
+	}
+
+	if strings.HasPrefix(address, listenUnixPrefix) {
+		path := strings.TrimPrefix(address, listenUnixPrefix)
+		// A socket left behind by an unclean exit would make Listen fail.
+		info, err := os.Stat(path)
+		if err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// validateListen checks the listen setting without opening anything.
+func validateListen(address string) error {
+	if address == listenSystemd {
+		return nil
+	}
+	if strings.HasPrefix(address, listenUnixPrefix) {
+		if strings.TrimPrefix(address, listenUnixPrefix) == "" {
+			return fmt.Errorf("listen %q is missing a socket path", address)
+		}
+		return nil
+	}
+	_, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("listen %q must be host:port, unix:/path or systemd: %s", address, err.Error())
+	}
+	return nil
+}
@@ -6,22 +6,24 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"flag"
 	"fmt"
-	"html/template"
 	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
+	"syscall"
 
 	"github.com/gorilla/sessions"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/walkingeyerobot/r38/db"
+	"github.com/walkingeyerobot/r38/drafts"
+	"github.com/walkingeyerobot/r38/makedraft"
 )
 
 type r38handler func(w http.ResponseWriter, r *http.Request, userId int64, tx *sql.Tx) error
@@ -30,52 +32,84 @@ type viewingFunc func(r *http.Request, userId int64) (bool, error)
 var secretKeyNoOneWillEverGuess = []byte(os.Getenv("SESSION_SECRET"))
 var store = sessions.NewCookieStore(secretKeyNoOneWillEverGuess)
 var isViewing viewingFunc
-var sock string
 
 func main() {
-	useAuthPtr := flag.Bool("auth", true, "bool")
-	flag.Parse()
-
-	useAuth := *useAuthPtr
+	var err error
+	config, err = LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Printf("%s", err.Error())
+		return
+	}
 
-	if useAuth {
+	if config.Auth {
 		isViewing = AuthIsViewing
 	} else {
 		isViewing = NonAuthIsViewing
 	}
 
-	var err error
-
-	database, err := sql.Open("sqlite3", "draft.db")
+	database, err := db.Open(config.DatabaseDriver, config.Database)
 	if err != nil {
+		log.Printf("error opening database: %s", err.Error())
 		return
 	}
-	err = database.Ping()
+	err = db.Migrate(database, config.DatabaseDriver)
 	if err != nil {
+		log.Printf("error migrating database: %s", err.Error())
 		return
 	}
 
-	port, valid := os.LookupEnv("R38_PORT")
-	if !valid {
-		port = "12264"
+	err = LoadTemplates()
+	if err != nil {
+		log.Printf("error loading templates: %s", err.Error())
+		return
 	}
 
-	sock, valid = os.LookupEnv("R38_SOCK")
-	if !valid {
-		sock = "./r38.sock"
+	listener, err := Listen(config.Listen)
+	if err != nil {
+		log.Printf("error listening at %q: %s", config.Listen, err.Error())
+		return
 	}
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", port),
-		Handler: NewHandler(database, useAuth),
+		Handler: NewHandler(database, config.Auth),
 	}
 
-	log.Printf("Starting HTTP Server. Listening at %q", server.Addr)
-	err = server.ListenAndServe() // this call blocks
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		var err error
+		if config.TLSCert != "" {
+			log.Printf("Starting HTTPS Server. Listening at %q", listener.Addr())
+			err = server.ServeTLS(listener, config.TLSCert, config.TLSKey)
+		} else {
+			log.Printf("Starting HTTP Server. Listening at %q", listener.Addr())
+			err = server.Serve(listener)
+		}
+		if err != http.ErrServerClosed {
+			log.Printf("%s", err.Error())
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
 
+	// Stop accepting connections, let in-flight picks finish, and then wait for any
+	// notifications they queued up.
+	log.Printf("shutting down, waiting up to %s", config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
 	if err != nil {
-		log.Printf("%s", err.Error())
+		log.Printf("error shutting down server: %s", err.Error())
+	}
+	err = WaitForBackground(shutdownCtx)
+	if err != nil {
+		log.Printf("gave up waiting for background work: %s", err.Error())
 	}
+	database.Close()
+	log.Printf("bye")
 }
 
 // NewHandler creates all server routes for serving the html.
@@ -110,7 +144,7 @@ func NewHandler(database *sql.DB, useAuth bool) http.Handler {
 				userID = 1
 			}
 
-			if userID == 1 {
+			if config.IsAdmin(userID) {
 				q := r.URL.Query()
 				val := q.Get("as")
 				if val != "" {
@@ -124,7 +158,7 @@ func NewHandler(database *sql.DB, useAuth bool) http.Handler {
 			}
 
 			ctx := r.Context()
-			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout)
 			defer cancel()
 
 			tx, err := database.BeginTx(ctx, &sql.TxOptions{ReadOnly: readonly})
@@ -133,9 +167,11 @@ func NewHandler(database *sql.DB, useAuth bool) http.Handler {
 				return
 			}
 
-			err = serveFunc(w, r, userID, tx)
+			// handlers can see the request's deadline through r.Context().
+			err = serveFunc(w, r.WithContext(ctx), userID, tx)
 			if err != nil {
 				tx.Rollback()
+				rollbacksTotal.WithLabelValues(route).Inc()
 				if strings.HasPrefix(route, "/api/") {
 					w.WriteHeader(http.StatusInternalServerError)
 					json.NewEncoder(w).Encode(JSONError{Error: err.Error()})
@@ -146,11 +182,26 @@ func NewHandler(database *sql.DB, useAuth bool) http.Handler {
 				tx.Commit()
 			}
 		})
-		mux.Handle(route, middleware(handler))
+		mux.Handle(route, instrument(route, middleware(handler)))
 	}
 
-	fs := http.FileServer(http.Dir("static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	static, err := StaticFileSystem()
+	if err != nil {
+		log.Printf("error serving static files: %s", err.Error())
+	} else {
+		fs := http.FileServer(static)
+		mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	}
+
+	// These are for monitoring and skip auth entirely. /metrics has its own token instead.
+	mux.HandleFunc("/healthz", ServeHealthz)
+	mux.Handle("/readyz", NewReadyzHandler(database))
+	if config.MetricsToken != "" {
+		mux.Handle("/metrics", requireMetricsToken(config.MetricsToken, NewMetricsHandler(database)))
+	} else {
+		log.Printf("metrics_token isn't set, so /metrics is turned off")
+		mux.Handle("/metrics", http.NotFoundHandler())
+	}
 
 	if useAuth {
 		addHandler("/auth/discord/login", oauthDiscordLogin, true) // don't actually need db at all
@@ -170,6 +221,17 @@ func NewHandler(database *sql.DB, useAuth bool) http.Handler {
 	addHandler("/api/pick/", ServeAPIPick, false)
 	addHandler("/api/join/", ServeAPIJoin, false)
 
+	addHandler("/api/admin/createdraft/", ServeAPIAdminCreateDraft, false)
+	addHandler("/api/admin/cubeversion/", ServeAPIAdminCubeVersion, false)
+	addHandler("/api/admin/suggestions/", ServeAPIAdminSuggestions, false)
+	addHandler("/api/admin/spectators/", ServeAPIAdminSpectators, false)
+	addHandler("/api/admin/invite/", ServeAPIAdminInvite, false)
+	addHandler("/api/cubeversions/", ServeAPICubeVersions, true)
+	addHandler("/api/cubediff/", ServeAPICubeDiff, true)
+	addHandler("/api/search/", ServeAPISearch, true)
+	addHandler("/api/suggest/", ServeAPISuggest, true)
+	addHandler("/api/signals/", ServeAPISignals, true)
+
 	addHandler("/", ServeIndex, true)
 
 	return mux
@@ -180,8 +242,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		session, err := store.Get(r, "session-name")
 		if err != nil {
-			t := template.Must(template.ParseFiles("login.tmpl"))
-			t.Execute(w, nil)
+			ExecuteTemplate(w, "login.tmpl", nil)
 			return
 		}
 		if session.Values["userid"] != nil {
@@ -189,8 +250,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		t := template.Must(template.ParseFiles("login.tmpl"))
-		t.Execute(w, nil)
+		ExecuteTemplate(w, "login.tmpl", nil)
 		return
 	})
 }
@@ -260,11 +320,12 @@ func ServeAPIDraftList(w http.ResponseWriter, r *http.Request, userID int64, tx
 	query := `select
                     drafts.id,
                     drafts.name,
-                    sum(seats.user is null and seats.position is not null) as empty_seats,
-                    coalesce(sum(seats.user = ?), 0) as joined
+                    coalesce(sum(case when seats.user is null and seats.position is not null then 1 else 0 end), 0) as empty_seats,
+                    coalesce(sum(case when seats.user = ? then 1 else 0 end), 0) as joined,
+                    drafts.private
                   from drafts
                   left join seats on drafts.id = seats.draft
-                  group by drafts.id`
+                  group by drafts.id, drafts.name, drafts.private`
 
 	rows, err := tx.Query(query, userID)
 	if err != nil {
@@ -275,13 +336,20 @@ func ServeAPIDraftList(w http.ResponseWriter, r *http.Request, userID int64, tx
 	for rows.Next() {
 		var d DraftListEntry
 		var joined int64
-		err = rows.Scan(&d.ID, &d.Name, &d.AvailableSeats, &joined)
+		var private sql.NullBool
+		err = rows.Scan(&d.ID, &d.Name, &d.AvailableSeats, &joined, &private)
 		if err != nil {
 			return fmt.Errorf("can't get draft list: %s", err.Error())
 		}
+		// private drafts are only listed for their players and admins.
+		d.Private = private.Valid && private.Bool
+		if d.Private && joined == 0 && !config.IsAdmin(userID) {
+			continue
+		}
 		if joined == 1 {
 			d.Status = "member"
-		} else if d.AvailableSeats == 0 {
+		} else if d.AvailableSeats == 0 || d.Private {
+			// private drafts can only be joined from an invite link.
 			d.Status = "spectator"
 		} else {
 			d.Status = "joinable"
@@ -322,6 +390,7 @@ func ServeAPIPick(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.
 			log.Printf("error making pick: %s", err.Error())
 			return fmt.Errorf("error making pick")
 		}
+		picksTotal.Inc()
 	} else if len(pick.CardIds) == 2 {
 		return fmt.Errorf("cogwork librarian power not implemented yet")
 	} else {
@@ -359,7 +428,7 @@ func ServeAPIJoin(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.
 
 	draftID := toJoin.ID
 
-	err = doJoin(tx, userID, draftID)
+	err = doJoin(tx, userID, draftID, toJoin.Code)
 	if err != nil {
 		return fmt.Errorf("error joining draft %d: %s", draftID, err.Error())
 	}
@@ -375,7 +444,7 @@ func ServeAPIJoin(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.
 
 // ServeBulkMTGO serves a .zip file of all .dek files for a draft. Only useful to the admin.
 func ServeBulkMTGO(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
-	if userID != 1 {
+	if !config.IsAdmin(userID) {
 		return fmt.Errorf("auth error in bulk export")
 	}
 	re := regexp.MustCompile(`/bulk_mtgo/(\d+)`)
@@ -454,8 +523,9 @@ func createZipExport(exports []BulkMTGOExport) ([]byte, error) {
 // exportToMTGO creates an MTGO compatible .dek string for given user and draft.
 func exportToMTGO(tx *sql.Tx, userID int64, draftID int64) (string, error) {
 	query := `select
-                    cards.data
+                    ` + makedraft.StoredCardColumns + `
                   from cards
+                  ` + makedraft.StoredCardJoin + `
                   join packs on cards.pack = packs.id
                   join seats on packs.seat = seats.id
                   where seats.user = ?
@@ -471,21 +541,20 @@ func exportToMTGO(tx *sql.Tx, userID int64, draftID int64) (string, error) {
 	var nq map[int64]NameAndQuantity
 	nq = make(map[int64]NameAndQuantity)
 	for rows.Next() {
-		var dataString string
-		var data R38CardData
-		err = rows.Scan(&dataString)
+		var card makedraft.StoredCard
+		err = rows.Scan(card.Scan()...)
 		if err != nil {
 			return "", err
 		}
-		err = json.Unmarshal([]byte(dataString), &data)
+		data, err := card.CardData()
 		if err != nil {
 			return "", err
 		}
-		if data.MTGO != 0 {
-			o := nq[data.MTGO]
+		if data.MtgoID != 0 {
+			o := nq[data.MtgoID]
 			o.Name = data.Scryfall.Name
 			o.Quantity++
-			nq[data.MTGO] = o
+			nq[data.MtgoID] = o
 		}
 	}
 	for mtgo, info := range nq {
@@ -517,15 +586,20 @@ func ServeVueApp(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.T
 
 	data := VuePageData{UserJSON: string(userInfoJSON)}
 
-	t := template.Must(template.ParseFiles("vue.tmpl"))
-
-	t.Execute(w, data)
-	return nil
+	return ExecuteTemplate(w, "vue.tmpl", data)
 }
 
 // ServeIndex serves the index page.
 func ServeIndex(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
-	query := `select drafts.id, drafts.name, sum(seats.user is null and seats.position is not null) as empty_seats, coalesce(sum(seats.user = ?), 0) as joined from drafts left join seats on drafts.id = seats.draft group by drafts.id`
+	query := `select
+                    drafts.id,
+                    drafts.name,
+                    coalesce(sum(case when seats.user is null and seats.position is not null then 1 else 0 end), 0) as empty_seats,
+                    coalesce(sum(case when seats.user = ? then 1 else 0 end), 0) as joined,
+                    drafts.private
+                  from drafts
+                  left join seats on drafts.id = seats.draft
+                  group by drafts.id, drafts.name, drafts.private`
 
 	rows, err := tx.Query(query, userID)
 	if err != nil {
@@ -535,24 +609,29 @@ func ServeIndex(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx
 	var Drafts []Draft
 	for rows.Next() {
 		var d Draft
-		err = rows.Scan(&d.ID, &d.Name, &d.Seats, &d.Joined)
+		var private sql.NullBool
+		err = rows.Scan(&d.ID, &d.Name, &d.Seats, &d.Joined, &private)
 		if err != nil {
 			return err
 		}
-		d.Joinable = d.Seats > 0 && !d.Joined
+		// private drafts are only listed for their players and admins.
+		d.Private = private.Valid && private.Bool
+		if d.Private && !d.Joined && !config.IsAdmin(userID) {
+			continue
+		}
+		d.Joinable = d.Seats > 0 && !d.Joined && !d.Private
 		d.Replayable = true
 
 		Drafts = append(Drafts, d)
 	}
 
 	viewParam := GetViewParam(r, userID)
-	data := IndexPageData{Drafts: Drafts, ViewURL: viewParam, UserID: userID}
-	t := template.Must(template.ParseFiles("index.tmpl"))
-	t.Execute(w, data)
-	return nil
+	data := IndexPageData{Drafts: Drafts, ViewURL: viewParam, UserID: userID, IsAdmin: config.IsAdmin(userID)}
+	return ExecuteTemplate(w, "index.tmpl", data)
 }
 
-// ServeJoin allows the user to join a draft, if possible.
+// ServeJoin allows the user to join a draft, if possible. Private drafts need their
+// invite code in ?code=.
 func ServeJoin(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
 	re := regexp.MustCompile(`/join/(\d+)`)
 	parseResult := re.FindStringSubmatch(r.URL.Path)
@@ -567,7 +646,7 @@ func ServeJoin(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx)
 	}
 	draftID := int64(draftIDInt)
 
-	err = doJoin(tx, userID, draftID)
+	err = doJoin(tx, userID, draftID, r.URL.Query().Get("code"))
 	if err != nil {
 		return err
 	}
@@ -577,16 +656,25 @@ func ServeJoin(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx)
 	return nil
 }
 
-// doJoin does the actual joining.
-func doJoin(tx *sql.Tx, userID int64, draftID int64) error {
+// doJoin does the actual joining. code is only checked for private drafts.
+func doJoin(tx *sql.Tx, userID int64, draftID int64, code string) error {
+	invite, err := drafts.GetInvite(tx, draftID)
+	if err != nil {
+		return err
+	}
+	err = invite.CheckInviteCode(code)
+	if err != nil {
+		return err
+	}
+
 	query := `select
                     count(1)
                   from seats
                   where draft = ?
-                    and user = ?`
+                    and "user" = ?`
 	row := tx.QueryRow(query, draftID, userID)
 	var alreadyJoined int64
-	err := row.Scan(&alreadyJoined)
+	err = row.Scan(&alreadyJoined)
 	if err != nil {
 		return err
 	} else if alreadyJoined > 0 {
@@ -597,7 +685,7 @@ func doJoin(tx *sql.Tx, userID int64, draftID int64) error {
                    id
                  from seats
                  where draft = ?
-                   and user is null
+                   and "user" is null
                  order by random()
                  limit 1`
 	row = tx.QueryRow(query, draftID)
@@ -607,7 +695,7 @@ func doJoin(tx *sql.Tx, userID int64, draftID int64) error {
 		return err
 	}
 
-	query = `update seats set user = ? where id = ?`
+	query = `update seats set "user" = ? where id = ?`
 	_, err = tx.Exec(query, userID, emptySeatID)
 	if err != nil {
 		return err
@@ -791,10 +879,7 @@ func doPick(tx *sql.Tx, userID int64, cardID int64, pass bool) (int64, int64, []
 				log.Printf("cannot determine if rounds match for notify")
 			} else if roundsMatch == 1 && newPositionDiscordID.Valid {
 				log.Printf("attempting to notify position %d draft %d", newPosition, draftID)
-				err = NotifyByDraftAndDiscordID(draftID, newPositionDiscordID.String)
-				if err != nil {
-					log.Printf("error with notify")
-				}
+				NotifyInBackground(draftID, newPositionDiscordID.String)
 			}
 
 			// Now that we've passed the pack, check to see if we should advance to the next round.
@@ -805,7 +890,7 @@ func doPick(tx *sql.Tx, userID int64, cardID int64, pass bool) (int64, int64, []
 			// If we're only doing normal drafts, round is effectively something that can be calculated,
 			// but by explicitly storing it, we allow ourselves the possibility of expanding support to
 			// weirder formats.
-			query = `update seats set round = ? where user = ? and draft = ?`
+			query = `update seats set round = ? where "user" = ? and draft = ?`
 
 			_, err = tx.Exec(query, (myCount+1)/15+1, userID, draftID)
 			if err != nil {
@@ -852,7 +937,7 @@ func doPick(tx *sql.Tx, userID int64, cardID int64, pass bool) (int64, int64, []
                                                  where v_packs.count > 0
                                                    and v_packs.round = seats.round
                                                    and seats.draft = ?
-                                                 group by seats.id`
+                                                 group by seats.id, seats.position, users.discord_id`
 
 					rows, err := tx.Query(query, draftID)
 					if err != nil {
@@ -873,10 +958,7 @@ func doPick(tx *sql.Tx, userID int64, cardID int64, pass bool) (int64, int64, []
 							}
 						}
 						if rowCount == 1 && blockingDiscordID.Valid {
-							err = NotifyByDraftAndDiscordID(draftID, blockingDiscordID.String)
-							if err != nil {
-								log.Printf("error with blocking notify")
-							}
+							NotifyInBackground(draftID, blockingDiscordID.String)
 						}
 					}
 				}
@@ -898,24 +980,65 @@ func doPick(tx *sql.Tx, userID int64, cardID int64, pass bool) (int64, int64, []
 	return draftID, myPackID, announcements, round, nil
 }
 
+// background tracks work that outlives the request that started it, like notifications.
+var background sync.WaitGroup
+
+// NotifyInBackground sends a discord alert to a user without holding up the pick.
+func NotifyInBackground(draftID int64, discordID string) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		err := NotifyByDraftAndDiscordID(draftID, discordID)
+		if err != nil {
+			log.Printf("error with notify: %s", err.Error())
+		}
+	}()
+}
+
+// WaitForBackground waits for background work to finish or for ctx to be done.
+func WaitForBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // NotifyByDraftAndDiscordID sends a discord alert to a user.
 func NotifyByDraftAndDiscordID(draftID int64, discordID string) error {
-	var jsonStr = []byte(fmt.Sprintf(`{"content": "<@%s> you have new picks <http://draft.thefoley.net/draft/%d>"}`, discordID, draftID))
-	req, err := http.NewRequest("POST", os.Getenv("DISCORD_WEBHOOK_URL"), bytes.NewBuffer(jsonStr))
+	err := notifyDiscord(draftID, discordID)
 	if err != nil {
-		return err
+		notificationFailuresTotal.Inc()
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return err
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// notifyDiscord posts the alert to every configured webhook.
+func notifyDiscord(draftID int64, discordID string) error {
+	var jsonStr = []byte(fmt.Sprintf(`{"content": "<@%s> you have new picks <%s/draft/%d>"}`, discordID, config.BaseURL, draftID))
+	for _, webhook := range config.DiscordWebhooks {
+		req, err := http.NewRequest("POST", webhook, bytes.NewBuffer(jsonStr))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Error sending msg. Status: %v", resp.Status)
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("Error sending msg. Status: %v", resp.Status)
+		}
 	}
 
 	return nil
@@ -933,13 +1056,14 @@ func GetJSONObject(tx *sql.Tx, draftID int64) (DraftJSON, error) {
                     users.discord_name,
                     cards.id,
                     users.id,
-                    cards.data,
-                    users.picture
+                    users.picture,
+                    ` + makedraft.StoredCardColumns + `
                   from seats
                   left join users on users.id = seats.user
                   join drafts on drafts.id = seats.draft
                   join packs on packs.original_seat = seats.id
                   join cards on cards.original_pack = packs.id
+                  ` + makedraft.StoredCardJoin + `
                   where drafts.id = ?`
 
 	rows, err := tx.Query(query, draftID)
@@ -954,26 +1078,25 @@ func GetJSONObject(tx *sql.Tx, draftID int64) (DraftJSON, error) {
 		var cardID int64
 		var nullableDiscordID sql.NullString
 		var draftUserID sql.NullInt64
-		var cardData string
+		var card makedraft.StoredCard
 		var nullablePicture sql.NullString
-		err = rows.Scan(&draft.DraftID, &draft.DraftName, &position, &packRound, &nullableDiscordID, &cardID, &draftUserID, &cardData, &nullablePicture)
+		err = rows.Scan(append([]interface{}{&draft.DraftID, &draft.DraftName, &position, &packRound, &nullableDiscordID, &cardID, &draftUserID, &nullablePicture}, card.Scan()...)...)
 		if err != nil {
 			return draft, err
 		}
 
-		dataObj := make(map[string]interface{})
-		err = json.Unmarshal([]byte(cardData), &dataObj)
+		cardData, err := card.CardData()
 		if err != nil {
-			log.Printf("making nil card data because of error %s", err.Error())
-			dataObj = nil
+			log.Printf("making empty card data because of error %s", err.Error())
+			cardData = makedraft.CardData{}
 		}
-		dataObj["id"] = cardID
+		cardData.ID = cardID
 
 		packRound--
 
 		nextIndex := indices[position][packRound]
 
-		draft.Seats[position].Packs[packRound][nextIndex] = dataObj
+		draft.Seats[position].Packs[packRound][nextIndex] = cardData
 		draft.Seats[position].PlayerName = nullableDiscordID.String
 		draft.Seats[position].PlayerID = draftUserID.Int64
 		draft.Seats[position].PlayerImage = nullablePicture.String
@@ -1026,33 +1149,98 @@ func GetJSONObject(tx *sql.Tx, draftID int64) (DraftJSON, error) {
 	return draft, nil
 }
 
-// GetFilteredJSON returns a filtered json object of replay data.
-func GetFilteredJSON(tx *sql.Tx, draftID int64, userID int64) (string, error) {
-	draft, err := GetJSONObject(tx, draftID)
+// isHiddenFrom reports whether a draft is private and the user is neither in it nor an
+// admin. Private drafts are hidden from everyone else, not just left out of lists.
+func isHiddenFrom(tx *sql.Tx, draftID int64, userID int64) (bool, error) {
+	invite, err := drafts.GetInvite(tx, draftID)
 	if err != nil {
-		return "", err
+		return false, err
+	}
+	if !invite.Private || config.IsAdmin(userID) {
+		return false, nil
+	}
+	var joined int64
+	err = tx.QueryRow(`select count(1) from seats where draft = ? and "user" = ?`, draftID, userID).Scan(&joined)
+	if err != nil {
+		return false, err
 	}
+	return joined == 0, nil
+}
 
+// canReadDraft returns an error if a user can't read anything about a draft, because
+// it's private and they're not in it.
+func canReadDraft(tx *sql.Tx, draftID int64, userID int64) error {
+	hidden, err := isHiddenFrom(tx, draftID, userID)
+	if err != nil {
+		return err
+	}
+	if hidden {
+		return fmt.Errorf("draft %d is private", draftID)
+	}
+	return nil
+}
+
+// canSeeWholeDraft reports whether a user can see every card and pick in a draft, because
+// either the draft is over for them, or they're not in the draft and it's full and either
+// over or open to live spectators.
+func canSeeWholeDraft(tx *sql.Tx, draftID int64, userID int64) (bool, error) {
 	query := `select (
                     select
                       round
                     from seats
                     where draft = ?
-                      and user = ?), (
+                      and "user" = ?), (
+                    select
+                      count(1)
+                    from seats
+                    where draft = ?
+                      and "user" is null), (
                     select
                       count(1)
                     from seats
                     where draft = ?
-                      and user is null)`
+                      and position is not null
+                      and round < 4)`
 	var myRound sql.NullInt64
 	var emptySeats int64
-	row := tx.QueryRow(query, draftID, userID, draftID)
-	err = row.Scan(&myRound, &emptySeats)
+	var drafting int64
+	row := tx.QueryRow(query, draftID, userID, draftID, draftID)
+	err := row.Scan(&myRound, &emptySeats, &drafting)
+	if err != nil {
+		return false, err
+	}
+	if myRound.Valid {
+		return myRound.Int64 >= 4, nil
+	}
+	if emptySeats > 0 {
+		return false, nil
+	}
+	if drafting == 0 {
+		return true, nil
+	}
+	mode, err := drafts.Spectators(tx, draftID)
+	if err != nil {
+		return false, err
+	}
+	return mode.Mode == drafts.SpectateLive, nil
+}
+
+// GetFilteredJSON returns a filtered json object of replay data.
+func GetFilteredJSON(tx *sql.Tx, draftID int64, userID int64) (string, error) {
+	err := canReadDraft(tx, draftID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	draft, err := GetJSONObject(tx, draftID)
+	if err != nil {
+		return "", err
+	}
+
+	wholeDraft, err := canSeeWholeDraft(tx, draftID, userID)
 	if err != nil {
 		return "", err
-	} else if (myRound.Valid && myRound.Int64 >= 4) || (!myRound.Valid && emptySeats == 0) {
-		// either we're not in the draft, or the draft is over for us
-		// therefore, we can see the whole draft.
+	} else if wholeDraft {
 		ret, err := json.Marshal(draft)
 		if err != nil {
 			return "", err
@@ -1060,14 +1248,39 @@ func GetFilteredJSON(tx *sql.Tx, draftID int64, userID int64) (string, error) {
 		return string(ret), nil
 	}
 
-	// this is an ongoing draft that we're a member of. filter the json.
-	conn, err := net.Dial("unix", sock)
+	perspective := Perspective{User: userID, Draft: draft}
+	spectating := true
+	for _, seat := range draft.Seats {
+		if seat.PlayerID == 0 || seat.PlayerID == userID {
+			spectating = false
+		}
+	}
+	if spectating {
+		mode, err := drafts.Spectators(tx, draftID)
+		if err != nil {
+			return "", err
+		}
+		switch mode.Mode {
+		case drafts.SpectateDelayed:
+			ret, err := json.Marshal(delayDraft(draft, mode.Delay))
+			if err != nil {
+				return "", err
+			}
+			return string(ret), nil
+		case drafts.SpectateSeat:
+			perspective.User = draft.Seats[mode.Seat].PlayerID
+		}
+	}
+
+	// this is an ongoing draft that we're a member of, or watching from a seat or not at all.
+	// filter the json.
+	conn, err := net.Dial("unix", config.FilterSocket)
 	if err != nil {
 		return "", err
 	}
 	defer conn.Close()
 
-	ret, err := json.Marshal(Perspective{User: userID, Draft: draft})
+	ret, err := json.Marshal(perspective)
 	if err != nil {
 		return "", err
 	}
@@ -1083,6 +1296,49 @@ func GetFilteredJSON(tx *sql.Tx, draftID int64, userID int64) (string, error) {
 	return buff.String(), nil
 }
 
+// delayDraft returns the draft as it was delay picks before the slowest seat's latest
+// pick, with the packs of rounds that hadn't started by then hidden.
+func delayDraft(draft DraftJSON, delay int64) DraftJSON {
+	var picks [8]int64
+	for _, event := range draft.Events {
+		if event.PlayerModified > picks[event.Position] {
+			picks[event.Position] = event.PlayerModified
+		}
+	}
+	cutoff := picks[0]
+	for _, n := range picks {
+		if n < cutoff {
+			cutoff = n
+		}
+	}
+	cutoff -= delay
+
+	events := []DraftEvent{}
+	round := int64(1)
+	for _, event := range draft.Events {
+		if event.PlayerModified <= cutoff {
+			events = append(events, event)
+			if event.Round > round {
+				round = event.Round
+			}
+		}
+	}
+	draft.Events = events
+
+	for i := range draft.Seats {
+		for j := round; j < 3; j++ {
+			for k, card := range draft.Seats[i].Packs[j] {
+				draft.Seats[i].Packs[j][k] = makedraft.CardData{
+					ID:       card.ID,
+					Hidden:   true,
+					Scryfall: makedraft.ScryfallData{Name: "Currently Unknown Card"},
+				}
+			}
+		}
+	}
+	return draft
+}
+
 // doEvent records an event (pick) into the database.
 func doEvent(tx *sql.Tx, draftID int64, userID int64, announcements []string, cardID1 int64, cardID2 sql.NullInt64, round int64) error {
 	query := `select
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// delayTestDraft makes a draft where each seat has made picks[seat] picks, 15 to a round,
+// with every seat's nth pick at time n.
+func delayTestDraft(picks [8]int64) DraftJSON {
+	var draft DraftJSON
+	for i := range draft.Seats {
+		for j := range draft.Seats[i].Packs {
+			for k := range draft.Seats[i].Packs[j] {
+				id := int64(i*100 + j*15 + k)
+				draft.Seats[i].Packs[j][k] = makedraft.CardData{ID: id, Scryfall: makedraft.ScryfallData{Name: fmt.Sprintf("card %d", id)}}
+			}
+		}
+	}
+	for n := int64(1); n <= 45; n++ {
+		for position, count := range picks {
+			if n <= count {
+				draft.Events = append(draft.Events, DraftEvent{
+					Position:       int64(position),
+					PlayerModified: n,
+					Round:          (n-1)/15 + 1,
+				})
+			}
+		}
+	}
+	return draft
+}
+
+func TestDelayDraft(t *testing.T) {
+	tests := []struct {
+		name       string
+		picks      [8]int64
+		delay      int64
+		wantEvents int
+		wantHidden []int
+	}{
+		{
+			name:       "no delay",
+			picks:      [8]int64{3, 3, 3, 3, 3, 3, 3, 3},
+			wantEvents: 24,
+			wantHidden: []int{1, 2},
+		},
+		{
+			name:       "delay",
+			picks:      [8]int64{3, 3, 3, 3, 3, 3, 3, 3},
+			delay:      1,
+			wantEvents: 16,
+			wantHidden: []int{1, 2},
+		},
+		{
+			name:       "slowest seat sets the cutoff",
+			picks:      [8]int64{5, 5, 5, 2, 5, 5, 5, 5},
+			delay:      1,
+			wantEvents: 8,
+			wantHidden: []int{1, 2},
+		},
+		{
+			name:       "a seat without picks hides everything",
+			picks:      [8]int64{5, 5, 5, 5, 5, 5, 5, 0},
+			wantEvents: 0,
+			wantHidden: []int{1, 2},
+		},
+		{
+			name:       "delay longer than the draft",
+			picks:      [8]int64{3, 3, 3, 3, 3, 3, 3, 3},
+			delay:      10,
+			wantEvents: 0,
+			wantHidden: []int{1, 2},
+		},
+		{
+			name:       "second round shows its packs",
+			picks:      [8]int64{20, 20, 20, 20, 20, 20, 20, 20},
+			delay:      4,
+			wantEvents: 128,
+			wantHidden: []int{2},
+		},
+		{
+			name:       "second round hidden until it's past the delay",
+			picks:      [8]int64{20, 20, 20, 20, 20, 20, 20, 20},
+			delay:      5,
+			wantEvents: 120,
+			wantHidden: []int{1, 2},
+		},
+		{
+			name:       "last round hides nothing",
+			picks:      [8]int64{45, 45, 45, 45, 45, 45, 45, 45},
+			delay:      3,
+			wantEvents: 336,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			draft := delayTestDraft(test.picks)
+			got := delayDraft(draft, test.delay)
+
+			if len(got.Events) != test.wantEvents {
+				t.Errorf("delayDraft() has %d events, want %d", len(got.Events), test.wantEvents)
+			}
+			cutoff := test.picks[0]
+			for _, n := range test.picks {
+				if n < cutoff {
+					cutoff = n
+				}
+			}
+			for _, event := range got.Events {
+				if event.PlayerModified > cutoff-test.delay {
+					t.Errorf("delayDraft() kept a pick made at %d, after the cutoff %d", event.PlayerModified, cutoff-test.delay)
+				}
+			}
+
+			hidden := make(map[int]bool)
+			for _, round := range test.wantHidden {
+				hidden[round] = true
+			}
+			for i, seat := range got.Seats {
+				for j, pack := range seat.Packs {
+					for k, card := range pack {
+						if card.ID != draft.Seats[i].Packs[j][k].ID {
+							t.Fatalf("seat %d pack %d card %d has id %d, want %d", i, j, k, card.ID, draft.Seats[i].Packs[j][k].ID)
+						}
+						if card.Hidden != hidden[j] {
+							t.Fatalf("seat %d pack %d card %d hidden = %v, want %v", i, j, k, card.Hidden, hidden[j])
+						}
+						if card.Hidden && card.Scryfall.Name != "Currently Unknown Card" {
+							t.Fatalf("seat %d pack %d card %d is hidden but named %q", i, j, k, card.Scryfall.Name)
+						}
+					}
+				}
+			}
+
+			if draft.Seats[0].Packs[2][0].Hidden {
+				t.Errorf("delayDraft() changed the draft it was given")
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package makedraft
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Balance groups, besides the five colors, that balanced pools sort cards into.
+const (
+	BalanceMulticolor = "multicolor"
+	BalanceColorless  = "colorless"
+	BalanceLand       = "land"
+)
+
+// BalanceGroups lists every group a card can be sorted into, in the order packs are planned.
+var BalanceGroups = []string{"W", "U", "B", "R", "G", BalanceMulticolor, BalanceColorless, BalanceLand}
+
+// Ways a balanced pool can sort cards.
+const (
+	BalanceByColor         = "color"
+	BalanceByColorIdentity = "color_identity"
+)
+
+// balanceGroup sorts a card by its colors, or its color identity.
+// Lands get a group of their own whatever their colors.
+func balanceGroup(card Card, info ScryfallData, identity bool) string {
+	if strings.Contains(info.TypeLine, "Land") {
+		return BalanceLand
+	}
+	colors := card.Color
+	if identity {
+		colors = card.ColorIdentity
+	}
+	switch len(colors) {
+	case 0:
+		return BalanceColorless
+	case 1:
+		return colors
+	}
+	return BalanceMulticolor
+}
+
+// balanceWindow is how many cards of a group a balanced pool looks at to find the one
+// that keeps a pack's colors closest to even.
+const balanceWindow = 8
+
+// BalancedHopper builds whole packs with an even mix of colors instead of drawing
+// cards at random, so packs from a cube don't need to be retried until they're balanced.
+// With Counts set, every pack gets exactly that many cards of each group. Otherwise
+// every pack gets each group's share of the cards that are left. Within a group it
+// picks the cards that keep the pack's colors even.
+type BalancedHopper struct {
+	Groups     map[string][]Card
+	Source     []Card
+	Counts     map[string]int
+	PerPack    int
+	Identity   bool
+	Refillable bool
+	pack       []Card
+	infos      cardInfos
+	rng        *rand.Rand
+}
+
+// Pop returns the next card of the pack and reports if the hopper is now empty.
+// The hopper is empty once it can't fill another pack.
+func (h *BalancedHopper) Pop() (Card, bool) {
+	if len(h.pack) == 0 {
+		h.pack = h.buildPack()
+	}
+	if len(h.pack) == 0 {
+		return Card{}, true
+	}
+	ret := h.pack[0]
+	h.pack = h.pack[1:]
+
+	var empty bool
+	if len(h.pack) == 0 {
+		empty = h.exhausted()
+		if h.Refillable && empty {
+			h.Refill()
+			empty = false
+		}
+	}
+	return ret, empty
+}
+
+// StartPack throws away what's left of the last pack.
+// It's safe to call more than once per pack when several slots share the pool.
+func (h *BalancedHopper) StartPack() {
+	h.pack = nil
+}
+
+// buildPack picks the cards for the next pack, in a random order.
+// It returns nothing once the hopper can't fill a pack.
+func (h *BalancedHopper) buildPack() []Card {
+	if h.exhausted() {
+		return nil
+	}
+	quotas := make(map[string]int)
+	if h.Counts != nil {
+		for group, count := range h.Counts {
+			quotas[group] = count
+		}
+	} else if total := h.remaining(); total > 0 {
+		// largest remainder, with ties broken at random.
+		perPack := h.PerPack
+		if perPack > total {
+			perPack = total
+		}
+		remainders := make(map[string]float64)
+		assigned := 0
+		for _, group := range BalanceGroups {
+			share := float64(len(h.Groups[group])*perPack) / float64(total)
+			quotas[group] = int(share)
+			remainders[group] = share - float64(quotas[group])
+			assigned += quotas[group]
+		}
+		order := append([]string(nil), BalanceGroups...)
+		h.rng.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+		sort.SliceStable(order, func(i, j int) bool {
+			return remainders[order[i]] > remainders[order[j]]
+		})
+		for _, group := range order[:perPack-assigned] {
+			quotas[group]++
+		}
+	}
+
+	// single colors go first so the rest can even them out.
+	var pack []Card
+	colors := make(map[rune]float64)
+	for _, group := range BalanceGroups {
+		for n := 0; n < quotas[group] && len(h.Groups[group]) > 0; n++ {
+			cards := h.Groups[group]
+			best := 0
+			bestStdev := math.Inf(1)
+			for i := 0; i < len(cards) && i < balanceWindow; i++ {
+				if colorStdev := h.stdevWith(colors, cards[i]); colorStdev < bestStdev {
+					best, bestStdev = i, colorStdev
+				}
+			}
+			card := cards[best]
+			cards[best] = cards[0]
+			h.Groups[group] = cards[1:]
+			for _, color := range h.colors(card) {
+				colors[color]++
+			}
+			pack = append(pack, card)
+		}
+	}
+	h.rng.Shuffle(len(pack), func(i, j int) {
+		pack[i], pack[j] = pack[j], pack[i]
+	})
+	return pack
+}
+
+// stdevWith is the standard deviation of the five colors in a pack if card were added.
+func (h *BalancedHopper) stdevWith(colors map[rune]float64, card Card) float64 {
+	cardColors := h.colors(card)
+	var counts []float64
+	for _, color := range "WUBRG" {
+		count := colors[color]
+		if strings.ContainsRune(cardColors, color) {
+			count++
+		}
+		counts = append(counts, count)
+	}
+	return stdev(counts)
+}
+
+func (h *BalancedHopper) colors(card Card) string {
+	if h.Identity {
+		return card.ColorIdentity
+	}
+	return card.Color
+}
+
+// exhausted reports if there aren't enough cards left for another pack.
+func (h *BalancedHopper) exhausted() bool {
+	if h.Counts == nil {
+		return h.remaining() == 0
+	}
+	for group, count := range h.Counts {
+		if len(h.Groups[group]) < count {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *BalancedHopper) remaining() int {
+	count := 0
+	for _, cards := range h.Groups {
+		count += len(cards)
+	}
+	return count
+}
+
+// Refill refills the hopper from its source cards.
+func (h *BalancedHopper) Refill() {
+	for _, v := range h.Source {
+		group := balanceGroup(v, h.infos.get(v), h.Identity)
+		h.Groups[group] = append(h.Groups[group], v)
+	}
+	for _, group := range BalanceGroups {
+		cards := h.Groups[group]
+		h.rng.Shuffle(len(cards), func(i, j int) {
+			cards[i], cards[j] = cards[j], cards[i]
+		})
+	}
+}
+
+// MakeBalancedHopper creates a BalancedHopper that fills perPack slots of every pack.
+// counts may be nil to follow the share of each group instead.
+func MakeBalancedHopper(rng *rand.Rand, refillable bool, identity bool, counts map[string]int, perPack int, sources ...[]Card) *BalancedHopper {
+	return newBalancedHopper(rng, nil, refillable, identity, counts, perPack, sources...)
+}
+
+// newBalancedHopper is MakeBalancedHopper with the cards' scryfall info already parsed.
+func newBalancedHopper(rng *rand.Rand, infos cardInfos, refillable bool, identity bool, counts map[string]int, perPack int, sources ...[]Card) *BalancedHopper {
+	ret := BalancedHopper{
+		Groups:     make(map[string][]Card),
+		Counts:     counts,
+		PerPack:    perPack,
+		Identity:   identity,
+		Refillable: refillable,
+		infos:      infos,
+		rng:        rng,
+	}
+	for _, cardList := range sources {
+		for _, v := range cardList {
+			var copiedCard Card
+			copiedCard = v // this copies???
+			ret.Source = append(ret.Source, copiedCard)
+		}
+	}
+	ret.Refill()
+	return &ret
+}
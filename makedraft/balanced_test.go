@@ -0,0 +1,197 @@
+package makedraft
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testBalancedCards has 10 commons of each color, 4 multicolor, 4 colorless and 4 lands.
+func testBalancedCards() []Card {
+	var cards []Card
+	for _, color := range []string{"W", "U", "B", "R", "G"} {
+		cards = append(cards, testCards(10, "common", color)...)
+	}
+	for i := 0; i < 4; i++ {
+		cards = append(cards, testCard(fmt.Sprintf("gold-%d", i), "common", "WU", "Creature", 2))
+		cards = append(cards, testCard(fmt.Sprintf("artifact-%d", i), "common", "", "Artifact", 2))
+		cards = append(cards, testCard(fmt.Sprintf("land-%d", i), "common", "", "Land", 0))
+	}
+	return cards
+}
+
+func TestBalanceGroup(t *testing.T) {
+	tests := []struct {
+		name     string
+		card     Card
+		identity bool
+		want     string
+	}{
+		{"mono color", testCard("a", "common", "R", "Creature", 2), false, "R"},
+		{"multicolor", testCard("a", "common", "BG", "Creature", 2), false, BalanceMulticolor},
+		{"colorless", testCard("a", "common", "", "Artifact", 2), false, BalanceColorless},
+		{"land with a color", testCard("a", "common", "G", "Land — Forest", 0), false, BalanceLand},
+		{"color over identity", Card{ID: "a", Color: "", ColorIdentity: "U"}, false, BalanceColorless},
+		{"identity over color", Card{ID: "a", Color: "", ColorIdentity: "U"}, true, "U"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info := cardInfos(nil).get(test.card)
+			if got := balanceGroup(test.card, info, test.identity); got != test.want {
+				t.Errorf("balanceGroup(%s) = %q, want %q", test.card.ID, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMakeBalancedHopper(t *testing.T) {
+	tests := []struct {
+		name    string
+		pool    PoolDefinition
+		perPack int
+		want    map[string]int
+		wantErr string
+	}{
+		{
+			name:    "shares",
+			pool:    PoolDefinition{Name: "common"},
+			perPack: 10,
+		},
+		{
+			name:    "counts",
+			pool:    PoolDefinition{Name: "common", Balance: map[string]int{"W": 2, "U": 2, "B": 2, "R": 2, "G": 2, BalanceMulticolor: 1, BalanceLand: 1}},
+			perPack: 12,
+			want:    map[string]int{"W": 2, "U": 2, "B": 2, "R": 2, "G": 2, BalanceMulticolor: 1, BalanceLand: 1},
+		},
+		{
+			name:    "counts by color identity",
+			pool:    PoolDefinition{Name: "common", BalanceBy: BalanceByColorIdentity, Balance: map[string]int{"W": 3, BalanceColorless: 2}},
+			perPack: 5,
+			want:    map[string]int{"W": 3, BalanceColorless: 2},
+		},
+		{
+			name:    "unknown balance_by",
+			pool:    PoolDefinition{Name: "common", BalanceBy: "rarity"},
+			perPack: 10,
+			wantErr: `pool "common" has unknown balance_by "rarity"`,
+		},
+		{
+			name:    "unknown group",
+			pool:    PoolDefinition{Name: "common", Balance: map[string]int{"purple": 10}},
+			perPack: 10,
+			wantErr: `pool "common" balances unknown group "purple"`,
+		},
+		{
+			name:    "not enough cards",
+			pool:    PoolDefinition{Name: "common", Balance: map[string]int{BalanceLand: 5, "W": 5}},
+			perPack: 10,
+			wantErr: `pool "common" has 4 land cards, not enough for 5 per pack`,
+		},
+		{
+			name:    "negative count",
+			pool:    PoolDefinition{Name: "common", Balance: map[string]int{"W": -1}},
+			perPack: 10,
+			wantErr: "not enough for -1 per pack",
+		},
+		{
+			name:    "counts don't fill the slots",
+			pool:    PoolDefinition{Name: "common", Balance: map[string]int{"W": 2, "U": 2}},
+			perPack: 10,
+			wantErr: `pool "common" balances 4 cards but 10 slots use it`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cards := testBalancedCards()
+			infos := makeCardInfos(cards)
+			h, err := makeBalancedHopper(&test.pool, cards, test.perPack, infos, rand.New(rand.NewSource(1)))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("makeBalancedHopper() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("makeBalancedHopper() error = %v", err)
+			}
+
+			h.StartPack()
+			groups := make(map[string]int)
+			for i := 0; i < test.perPack; i++ {
+				card, _ := h.Pop()
+				if card.ID == "" {
+					t.Fatalf("pack ran out after %d cards, want %d", i, test.perPack)
+				}
+				groups[balanceGroup(card, infos.get(card), h.Identity)]++
+			}
+			if test.want != nil {
+				for _, group := range BalanceGroups {
+					if groups[group] != test.want[group] {
+						t.Errorf("pack has %d %s cards, want %d", groups[group], group, test.want[group])
+					}
+				}
+				return
+			}
+			for _, color := range []string{"W", "U", "B", "R", "G"} {
+				if groups[color] < 1 || groups[color] > 2 {
+					t.Errorf("pack has %d %s cards, want its share of 1 or 2", groups[color], color)
+				}
+			}
+		})
+	}
+}
+
+func TestBalancedHopperPop(t *testing.T) {
+	tests := []struct {
+		name       string
+		refillable bool
+		counts     map[string]int
+		packs      int
+		wantEmpty  bool
+	}{
+		{"shares run out with the cards", false, nil, 3, true},
+		{"counts run out with a group", false, map[string]int{"W": 4, "U": 4, "B": 2}, 2, true},
+		{"counts with cards to spare", false, map[string]int{"W": 4, "U": 4, "B": 2}, 1, false},
+		{"refill", true, nil, 4, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var cards []Card
+			for _, color := range []string{"W", "U", "B"} {
+				cards = append(cards, testCards(10, "common", color)...)
+			}
+			h := newBalancedHopper(rand.New(rand.NewSource(1)), nil, test.refillable, false, test.counts, 10, cards)
+
+			seen := make(map[string]bool)
+			var empty bool
+			for pack := 0; pack < test.packs; pack++ {
+				h.StartPack()
+				for i := 0; i < 10; i++ {
+					if empty && !test.refillable {
+						t.Fatalf("hopper emptied early, in pack %d", pack)
+					}
+					var card Card
+					card, empty = h.Pop()
+					if card.ID == "" {
+						t.Fatalf("got no card in pack %d", pack)
+					}
+					if !test.refillable && seen[card.ID] {
+						t.Errorf("got %s twice", card.ID)
+					}
+					seen[card.ID] = true
+				}
+			}
+			if empty != test.wantEmpty {
+				t.Errorf("empty = %v after %d packs, want %v", empty, test.packs, test.wantEmpty)
+			}
+
+			if empty {
+				h.StartPack()
+				if card, empty := h.Pop(); card.ID != "" || !empty {
+					t.Errorf("Pop() on an empty hopper = %s, %v, want no card and true", card.ID, empty)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package makedraft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FoilStatus is the placeholder set files use for foil when whether a card is foil
+// depends on the slot it's put in.
+const FoilStatus = "FOIL_STATUS"
+
+// CardData is a card's json data. Set files keep it as a string in each card's data,
+// and the client gets it for every card in a draft. Hidden cards only have an ID and
+// a placeholder name.
+type CardData struct {
+	ID        int64        `json:"id,omitempty"`
+	Hidden    bool         `json:"hidden,omitempty"`
+	Foil      Foil         `json:"foil"`
+	Scryfall  ScryfallData `json:"scryfall"`
+	ImageURIs []string     `json:"image_uris"`
+	Rating    *float64     `json:"rating,omitempty"`
+	MtgoID    int64        `json:"mtgo_id,omitempty"`
+	SetName   string       `json:"set_name,omitempty"`
+}
+
+// ScryfallData is the part of a card's scryfall json that r38 keeps.
+// Colors is missing, rather than empty, for cards with more than one face.
+type ScryfallData struct {
+	Cmc             float64    `json:"cmc"`
+	ColorIdentity   []string   `json:"color_identity"`
+	Layout          string     `json:"layout"`
+	Name            string     `json:"name"`
+	TypeLine        string     `json:"type_line"`
+	CollectorNumber string     `json:"collector_number"`
+	Rarity          string     `json:"rarity"`
+	Set             string     `json:"set"`
+	CardFaces       []CardFace `json:"card_faces,omitempty"`
+	Colors          *[]string  `json:"colors,omitempty"`
+	ManaCost        string     `json:"mana_cost,omitempty"`
+}
+
+// CardFace is one face of a card with more than one.
+type CardFace struct {
+	ManaCost string    `json:"mana_cost"`
+	Name     string    `json:"name"`
+	TypeLine string    `json:"type_line"`
+	Colors   *[]string `json:"colors,omitempty"`
+}
+
+// Foil is whether a card is foil. In set files it can also be FoilStatus, in which
+// case FromSlot is set and the slot the card is put in decides.
+type Foil struct {
+	Foil     bool
+	FromSlot bool
+}
+
+// MarshalJSON writes a Foil as true, false or "FOIL_STATUS".
+func (f Foil) MarshalJSON() ([]byte, error) {
+	if f.FromSlot {
+		return json.Marshal(FoilStatus)
+	}
+	return json.Marshal(f.Foil)
+}
+
+// UnmarshalJSON reads a Foil from true, false or "FOIL_STATUS".
+func (f *Foil) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case bool:
+		*f = Foil{Foil: value}
+	case string:
+		if value != FoilStatus {
+			return fmt.Errorf("foil is %q, not true, false or %q", value, FoilStatus)
+		}
+		*f = Foil{FromSlot: true}
+	case nil:
+		*f = Foil{}
+	default:
+		return fmt.Errorf("foil is %v, not true, false or %q", value, FoilStatus)
+	}
+	return nil
+}
+
+// ParseCardData reads a card's json data. Fields r38 doesn't know about are ignored.
+func ParseCardData(data string) (CardData, error) {
+	var ret CardData
+	err := json.Unmarshal([]byte(data), &ret)
+	return ret, err
+}
+
+// Resolve fills in whether this copy of the card is foil, given whether its slot is.
+func (d CardData) Resolve(slotFoil bool) CardData {
+	if d.Foil.FromSlot {
+		d.Foil = Foil{Foil: slotFoil}
+	}
+	return d
+}
+
+// Encode writes the card's json data the same way every time, without escaping html.
+func (d CardData) Encode() (string, error) {
+	return encodeJSON(d)
+}
+
+func encodeJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// Colors returns the card's colors, or its front face's colors if it has more than one.
+func (d CardData) Colors() []string {
+	if d.Scryfall.Colors != nil {
+		return *d.Scryfall.Colors
+	}
+	if len(d.Scryfall.CardFaces) > 0 && d.Scryfall.CardFaces[0].Colors != nil {
+		return *d.Scryfall.CardFaces[0].Colors
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package makedraft
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// catalogData is the part of a card's json data that's the same for every copy of the card,
+// so it's kept once in the catalog table.
+type catalogData struct {
+	Scryfall  ScryfallData `json:"scryfall"`
+	ImageURIs []string     `json:"image_uris"`
+}
+
+// cardDetails is the rest of a card's json data, apart from foil and mtgo_id, which have
+// columns of their own. It's kept with each copy in cards.details.
+type cardDetails struct {
+	Rating  *float64 `json:"rating,omitempty"`
+	SetName string   `json:"set_name,omitempty"`
+}
+
+// StoredCard is a card as stored in the database, ready to be turned back into json.
+// Scan the columns listed in StoredCardColumns into it, after joining the catalog
+// with StoredCardJoin.
+type StoredCard struct {
+	CatalogData sql.NullString
+	Details     sql.NullString
+	Foil        sql.NullBool
+	Mtgo        sql.NullString
+	Data        sql.NullString
+}
+
+// StoredCardColumns and StoredCardJoin are the parts of a query that read a StoredCard.
+const (
+	StoredCardColumns = `catalog.data, cards.details, cards.foil, cards.mtgo, cards.data`
+	StoredCardJoin    = `left join catalog on catalog.id = cards.catalog`
+)
+
+// Scan points at each of the StoredCardColumns in order.
+func (c *StoredCard) Scan() []interface{} {
+	return []interface{}{&c.CatalogData, &c.Details, &c.Foil, &c.Mtgo, &c.Data}
+}
+
+// CardData rebuilds the card's json data, the way it looked in its set file but with foil filled in.
+// Cards inserted before the catalog existed still have all their json in cards.data.
+func (c *StoredCard) CardData() (CardData, error) {
+	if !c.CatalogData.Valid {
+		if !c.Data.Valid {
+			return CardData{}, fmt.Errorf("card has no data")
+		}
+		data, err := ParseCardData(c.Data.String)
+		return data.Resolve(false), err
+	}
+
+	var catalog catalogData
+	err := json.Unmarshal([]byte(c.CatalogData.String), &catalog)
+	if err != nil {
+		return CardData{}, err
+	}
+	var details cardDetails
+	if c.Details.Valid && c.Details.String != "" {
+		err = json.Unmarshal([]byte(c.Details.String), &details)
+		if err != nil {
+			return CardData{}, err
+		}
+	}
+	data := CardData{
+		Foil:      Foil{Foil: c.Foil.Bool},
+		Scryfall:  catalog.Scryfall,
+		ImageURIs: catalog.ImageURIs,
+		Rating:    details.Rating,
+		SetName:   details.SetName,
+	}
+	if c.Mtgo.Valid {
+		data.MtgoID, err = strconv.ParseInt(c.Mtgo.String, 10, 64)
+		if err != nil {
+			return data, fmt.Errorf("bad mtgo id %q: %s", c.Mtgo.String, err.Error())
+		}
+	}
+	return data, nil
+}
+
+// copyColumns is what's kept with each copy of a card: its details and mtgo id.
+// Foil is kept too, once it's been resolved.
+func copyColumns(data CardData) (details sql.NullString, mtgo sql.NullString, err error) {
+	if data.MtgoID != 0 {
+		mtgo = sql.NullString{String: strconv.FormatInt(data.MtgoID, 10), Valid: true}
+	}
+	rest := cardDetails{Rating: data.Rating, SetName: data.SetName}
+	if rest != (cardDetails{}) {
+		details.String, err = encodeJSON(rest)
+		details.Valid = err == nil
+	}
+	return
+}
+
+// catalogCard finds or creates the catalog entry for a card and returns its id.
+// Cards are found by scryfall id, or by set and collector number if they don't have one
+// or no entry has their scryfall id yet.
+// ids caches lookups for the rest of the transaction.
+func catalogCard(tx *sql.Tx, scryfallID string, color string, colorIdentity string, rarity string, data CardData, ids map[string]int64) (int64, error) {
+	info := data.Scryfall
+
+	key := scryfallID
+	if key == "" {
+		key = info.Set + "/" + info.CollectorNumber
+	}
+	if id, ok := ids[key]; ok {
+		return id, nil
+	}
+
+	var id int64
+	var err error
+	if scryfallID != "" {
+		err = tx.QueryRow(`select id from catalog where scryfall_id = ?`, scryfallID).Scan(&id)
+		if err == sql.ErrNoRows {
+			// cards moved from before the catalog existed have no scryfall id, so claim
+			// their entry for this printing instead of adding another.
+			err = tx.QueryRow(`select id from catalog where scryfall_id is null and edition = ? and number = ? order by id limit 1`,
+				info.Set, info.CollectorNumber).Scan(&id)
+			if err == nil {
+				_, err = tx.Exec(`UPDATE catalog SET scryfall_id = ? WHERE id = ?`, scryfallID, id)
+			}
+		}
+	} else {
+		err = tx.QueryRow(`select id from catalog where edition = ? and number = ? order by id limit 1`, info.Set, info.CollectorNumber).Scan(&id)
+	}
+	if err == sql.ErrNoRows {
+		var nullableID sql.NullString
+		if scryfallID != "" {
+			nullableID = sql.NullString{String: scryfallID, Valid: true}
+		}
+		encoded, err := encodeJSON(catalogData{Scryfall: info, ImageURIs: data.ImageURIs})
+		if err != nil {
+			return 0, err
+		}
+		res, err := tx.Exec(`INSERT INTO catalog (scryfall_id, name, edition, number, cmc, type, color, color_identity, rarity, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			nullableID, info.Name, info.Set, info.CollectorNumber, info.Cmc, info.TypeLine, stringSort(color), stringSort(colorIdentity), rarity, encoded)
+		if err != nil {
+			return 0, fmt.Errorf("error inserting %s into the catalog: %s", info.Name, err.Error())
+		}
+		id, err = res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+	} else if err != nil {
+		return 0, fmt.Errorf("error looking up %s in the catalog: %s", info.Name, err.Error())
+	}
+	ids[key] = id
+	return id, nil
+}
+
+// MoveCardsToCatalog moves cards inserted before the catalog existed into it. Their
+// cards.data is left alone, since it can have fields the catalog doesn't keep; CardData
+// only reads it for cards with no catalog entry. It returns how many cards it moved.
+func MoveCardsToCatalog(tx *sql.Tx) (int, error) {
+	rows, err := tx.Query(`select id, data from cards where catalog is null and data is not null`)
+	if err != nil {
+		return 0, err
+	}
+	type legacyCard struct {
+		id   int64
+		data string
+	}
+	var legacy []legacyCard
+	for rows.Next() {
+		var card legacyCard
+		err = rows.Scan(&card.id, &card.data)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		legacy = append(legacy, card)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	ids := make(map[string]int64)
+	for _, card := range legacy {
+		data, err := ParseCardData(card.data)
+		if err != nil {
+			return 0, fmt.Errorf("error reading card %d: %s", card.id, err.Error())
+		}
+		data = data.Resolve(false)
+		info := data.Scryfall
+		rarity := info.Rarity
+		if strings.Contains(info.TypeLine, "Basic Land") {
+			rarity = "basic"
+		}
+		catalogID, err := catalogCard(tx, "", strings.Join(data.Colors(), ""), strings.Join(info.ColorIdentity, ""), rarity, data, ids)
+		if err != nil {
+			return 0, err
+		}
+		details, mtgo, err := copyColumns(data)
+		if err != nil {
+			return 0, fmt.Errorf("error moving card %d: %s", card.id, err.Error())
+		}
+		_, err = tx.Exec(`UPDATE cards SET catalog = ?, foil = ?, mtgo = ?, details = ? WHERE id = ?`,
+			catalogID, data.Foil.Foil, mtgo, details, card.id)
+		if err != nil {
+			return 0, fmt.Errorf("error moving card %d: %s", card.id, err.Error())
+		}
+	}
+	return len(legacy), nil
+}
@@ -0,0 +1,304 @@
+package makedraft
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// CubeVersion is one saved list of a cube. Versions of a cube are numbered from 1.
+type CubeVersion struct {
+	ID      int64  `json:"id"`
+	Cube    string `json:"cube"`
+	Version int64  `json:"version"`
+	Note    string `json:"note"`
+	Created int64  `json:"created"`
+	Cards   int64  `json:"cards"`
+}
+
+// CubeDiff lists the cards added and cut between two versions of a cube.
+type CubeDiff struct {
+	From CubeVersion `json:"from"`
+	To   CubeVersion `json:"to"`
+	Adds []CubeCard  `json:"adds"`
+	Cuts []CubeCard  `json:"cuts"`
+}
+
+// CubeCard is a card in a CubeDiff.
+type CubeCard struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Copies int    `json:"copies"`
+}
+
+// CubeNameRegexp matches the names cubes can be saved under, which are safe to put in urls.
+var CubeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// SaveCubeVersion stores cfg as the next version of a cube, creating the cube if it's new.
+// Cards are stored one row each so versions can be compared in SQL; the rest of cfg is
+// kept as json.
+func SaveCubeVersion(tx *sql.Tx, cube string, note string, cfg DraftConfig) (CubeVersion, error) {
+	ret := CubeVersion{Cube: cube, Note: note, Created: time.Now().Unix(), Cards: int64(len(cfg.Cards))}
+
+	if cube == "" {
+		return ret, fmt.Errorf("a cube needs a name")
+	}
+	if !CubeNameRegexp.MatchString(cube) {
+		return ret, fmt.Errorf("invalid cube name %q: use only letters, numbers, - and _", cube)
+	}
+	if len(cfg.Cards) == 0 {
+		return ret, fmt.Errorf("cube %s has no cards", cube)
+	}
+
+	var cubeID int64
+	err := tx.QueryRow(`select id from cubes where name = ?`, cube).Scan(&cubeID)
+	if err == sql.ErrNoRows {
+		res, err := tx.Exec(`INSERT INTO cubes (name) VALUES (?)`, cube)
+		if err != nil {
+			return ret, fmt.Errorf("error creating cube %s: %s", cube, err.Error())
+		}
+		cubeID, err = res.LastInsertId()
+		if err != nil {
+			return ret, err
+		}
+	} else if err != nil {
+		return ret, fmt.Errorf("error finding cube %s: %s", cube, err.Error())
+	}
+
+	// versions are unique per cube, so if another save takes this number first, the insert fails.
+	err = tx.QueryRow(`select coalesce(max(version), 0) + 1 from cube_versions where cube = ?`, cubeID).Scan(&ret.Version)
+	if err != nil {
+		return ret, fmt.Errorf("error numbering cube version: %s", err.Error())
+	}
+
+	rest := cfg
+	rest.Cards = nil
+	config, err := json.Marshal(rest)
+	if err != nil {
+		return ret, err
+	}
+	res, err := tx.Exec(`INSERT INTO cube_versions (cube, version, note, created, config) VALUES (?, ?, ?, ?, ?)`,
+		cubeID, ret.Version, note, ret.Created, string(config))
+	if err != nil {
+		return ret, fmt.Errorf("error inserting cube version: %s", err.Error())
+	}
+	ret.ID, err = res.LastInsertId()
+	if err != nil {
+		return ret, err
+	}
+
+	query := `INSERT INTO cube_version_cards (cube_version, position, card_id, name, card) VALUES (?, ?, ?, ?, ?)`
+	for i, card := range cfg.Cards {
+		cardJSON, err := json.Marshal(card)
+		if err != nil {
+			return ret, err
+		}
+		_, err = tx.Exec(query, ret.ID, i, card.ID, CardName(card), string(cardJSON))
+		if err != nil {
+			return ret, fmt.Errorf("error inserting cube card: %s", err.Error())
+		}
+	}
+
+	return ret, nil
+}
+
+// ChangeCube saves a new version of a cube from its latest version, with cards added
+// and one copy of each cut card id removed.
+func ChangeCube(tx *sql.Tx, cube string, note string, adds []Card, cuts []string) (CubeVersion, error) {
+	cfg, _, err := LoadCubeVersion(tx, cube, 0)
+	if err != nil {
+		return CubeVersion{}, err
+	}
+	for _, cut := range cuts {
+		found := false
+		for i, card := range cfg.Cards {
+			if card.ID == cut {
+				cfg.Cards = append(cfg.Cards[:i], cfg.Cards[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return CubeVersion{}, fmt.Errorf("can't cut %s, it isn't in cube %s", cut, cube)
+		}
+	}
+	cfg.Cards = append(cfg.Cards, adds...)
+	return SaveCubeVersion(tx, cube, note, cfg)
+}
+
+const cubeVersionQuery = `select
+                    cube_versions.id,
+                    cubes.name,
+                    cube_versions.version,
+                    cube_versions.note,
+                    cube_versions.created,
+                    count(cube_version_cards.id)
+                  from cube_versions
+                  join cubes on cubes.id = cube_versions.cube
+                  left join cube_version_cards on cube_version_cards.cube_version = cube_versions.id`
+
+const cubeVersionGroupBy = ` group by cube_versions.id, cubes.name, cube_versions.version, cube_versions.note, cube_versions.created`
+
+func scanCubeVersion(row interface{ Scan(...interface{}) error }) (CubeVersion, error) {
+	var ret CubeVersion
+	err := row.Scan(&ret.ID, &ret.Cube, &ret.Version, &ret.Note, &ret.Created, &ret.Cards)
+	return ret, err
+}
+
+// GetCubeVersion looks up a cube version by its id.
+func GetCubeVersion(tx *sql.Tx, versionID int64) (CubeVersion, error) {
+	ret, err := scanCubeVersion(tx.QueryRow(cubeVersionQuery+` where cube_versions.id = ?`+cubeVersionGroupBy, versionID))
+	if err == sql.ErrNoRows {
+		return ret, fmt.Errorf("no cube version %d", versionID)
+	} else if err != nil {
+		return ret, fmt.Errorf("error getting cube version %d: %s", versionID, err.Error())
+	}
+	return ret, nil
+}
+
+// FindCubeVersion looks up a version of a cube by its number, or the latest version if it's 0.
+func FindCubeVersion(tx *sql.Tx, cube string, version int64) (CubeVersion, error) {
+	var versionID int64
+	var err error
+	if version == 0 {
+		err = tx.QueryRow(`select cube_versions.id from cube_versions join cubes on cubes.id = cube_versions.cube
+                             where cubes.name = ? order by cube_versions.version desc limit 1`, cube).Scan(&versionID)
+	} else {
+		err = tx.QueryRow(`select cube_versions.id from cube_versions join cubes on cubes.id = cube_versions.cube
+                             where cubes.name = ? and cube_versions.version = ?`, cube, version).Scan(&versionID)
+	}
+	if err == sql.ErrNoRows {
+		if version == 0 {
+			return CubeVersion{}, fmt.Errorf("cube %s has no versions", cube)
+		}
+		return CubeVersion{}, fmt.Errorf("cube %s has no version %d", cube, version)
+	} else if err != nil {
+		return CubeVersion{}, fmt.Errorf("error finding cube %s: %s", cube, err.Error())
+	}
+	return GetCubeVersion(tx, versionID)
+}
+
+// ListCubeVersions lists every version of a cube, oldest first.
+func ListCubeVersions(tx *sql.Tx, cube string) ([]CubeVersion, error) {
+	rows, err := tx.Query(cubeVersionQuery+` where cubes.name = ?`+cubeVersionGroupBy+` order by cube_versions.version`, cube)
+	if err != nil {
+		return nil, fmt.Errorf("error listing cube versions: %s", err.Error())
+	}
+	defer rows.Close()
+	ret := []CubeVersion{}
+	for rows.Next() {
+		version, err := scanCubeVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error listing cube versions: %s", err.Error())
+		}
+		ret = append(ret, version)
+	}
+	return ret, rows.Err()
+}
+
+// LoadCubeVersion rebuilds the set json for a version of a cube, or its latest version if version is 0.
+func LoadCubeVersion(tx *sql.Tx, cube string, version int64) (DraftConfig, CubeVersion, error) {
+	var cfg DraftConfig
+	found, err := FindCubeVersion(tx, cube, version)
+	if err != nil {
+		return cfg, found, err
+	}
+
+	var config string
+	err = tx.QueryRow(`select config from cube_versions where id = ?`, found.ID).Scan(&config)
+	if err != nil {
+		return cfg, found, fmt.Errorf("error loading cube version: %s", err.Error())
+	}
+	err = json.Unmarshal([]byte(config), &cfg)
+	if err != nil {
+		return cfg, found, fmt.Errorf("error unmarshalling cube version: %s", err.Error())
+	}
+
+	rows, err := tx.Query(`select card from cube_version_cards where cube_version = ? order by position`, found.ID)
+	if err != nil {
+		return cfg, found, fmt.Errorf("error loading cube cards: %s", err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cardJSON string
+		err = rows.Scan(&cardJSON)
+		if err != nil {
+			return cfg, found, fmt.Errorf("error loading cube cards: %s", err.Error())
+		}
+		var card Card
+		err = json.Unmarshal([]byte(cardJSON), &card)
+		if err != nil {
+			return cfg, found, fmt.Errorf("error unmarshalling cube card: %s", err.Error())
+		}
+		cfg.Cards = append(cfg.Cards, card)
+	}
+	return cfg, found, rows.Err()
+}
+
+// DiffCubeVersions compares two cube versions by their ids. Copies are counted,
+// so going from one copy of a card to two is an add.
+func DiffCubeVersions(tx *sql.Tx, fromID int64, toID int64) (CubeDiff, error) {
+	var ret CubeDiff
+	var err error
+	ret.From, err = GetCubeVersion(tx, fromID)
+	if err != nil {
+		return ret, err
+	}
+	ret.To, err = GetCubeVersion(tx, toID)
+	if err != nil {
+		return ret, err
+	}
+
+	names := make(map[string]string)
+	count := func(versionID int64) (map[string]int, error) {
+		rows, err := tx.Query(`select card_id, name from cube_version_cards where cube_version = ?`, versionID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading cube cards: %s", err.Error())
+		}
+		defer rows.Close()
+		counts := make(map[string]int)
+		for rows.Next() {
+			var id, name string
+			err = rows.Scan(&id, &name)
+			if err != nil {
+				return nil, fmt.Errorf("error loading cube cards: %s", err.Error())
+			}
+			counts[id]++
+			names[id] = name
+		}
+		return counts, rows.Err()
+	}
+	from, err := count(fromID)
+	if err != nil {
+		return ret, err
+	}
+	to, err := count(toID)
+	if err != nil {
+		return ret, err
+	}
+
+	ret.Adds = []CubeCard{}
+	ret.Cuts = []CubeCard{}
+	for id, copies := range to {
+		if copies > from[id] {
+			ret.Adds = append(ret.Adds, CubeCard{ID: id, Name: names[id], Copies: copies - from[id]})
+		}
+	}
+	for id, copies := range from {
+		if copies > to[id] {
+			ret.Cuts = append(ret.Cuts, CubeCard{ID: id, Name: names[id], Copies: copies - to[id]})
+		}
+	}
+	for _, cards := range [][]CubeCard{ret.Adds, ret.Cuts} {
+		sort.Slice(cards, func(i, j int) bool {
+			if cards[i].Name != cards[j].Name {
+				return cards[i].Name < cards[j].Name
+			}
+			return cards[i].ID < cards[j].ID
+		})
+	}
+	return ret, nil
+}
@@ -1,20 +1,26 @@
-package main
+package makedraft
 
 import (
+	"fmt"
 	"math/rand"
 )
 
 // DraftConfig stores is directly imported from the set json file.
+// Packs are described either by Pools and Slots, or by the older Hoppers list.
 type DraftConfig struct {
-	Hoppers []HopperDefinition `json:"hoppers"`
+	Sheets  []SheetDefinition  `json:"sheets,omitempty"`
+	Pools   []PoolDefinition   `json:"pools,omitempty"`
+	Slots   []SlotDefinition   `json:"slots,omitempty"`
+	Hoppers []HopperDefinition `json:"hoppers,omitempty"`
+	Rules   []RuleDefinition   `json:"rules,omitempty"`
 	Flags   []string           `json:"flags"`
 	Cards   []Card             `json:"cards"`
 }
 
-// HopperDefinition is part of DraftConfig and describes hoppers.
+// HopperDefinition is part of DraftConfig and describes hoppers in older set json files.
 type HopperDefinition struct {
 	Type string  `json:"type"`
-	Refs []int64 `json:"refs"`
+	Refs []int64 `json:"refs,omitempty"`
 }
 
 // Card is part of DraftConfig and describes cards.
@@ -28,7 +34,7 @@ type Card struct {
 	Rarity        string  `json:"rarity"`
 	Rating        float64 `json:"rating"`
 	Data          string  `json:"data"`
-	Foil          bool
+	Foil          bool    `json:"-"`
 }
 
 // CardSet helps us lookup cards by rarity.
@@ -52,6 +58,7 @@ type NormalHopper struct {
 	Cards      []Card
 	Source     []Card
 	Refillable bool
+	rng        *rand.Rand
 }
 
 // FoilHopper has a 1/4 chance to return a foil card from its own cards and 3/4 chance to return a non-foil card from OtherHoppers[].
@@ -59,6 +66,7 @@ type FoilHopper struct {
 	OtherHoppers []*Hopper
 	Cards        []Card
 	Source       []Card
+	rng          *rand.Rand
 }
 
 // BasicLandHopper is never empty and always returns a random basic.
@@ -67,8 +75,27 @@ type BasicLandHopper struct {
 	Source []Card
 }
 
-// Pop returns a card from the hopper and reports if the hopper is now empty.
+// SheetHopper emulates real booster collation by cutting contiguous runs of cards
+// from a print sheet. Each pack starts its run at a random spot on the sheet and the
+// sheet wraps around, so it is never empty.
+type SheetHopper struct {
+	Sheet    []Card
+	position int
+	cut      bool
+	rng      *rand.Rand
+}
+
+// PackStarter is implemented by hoppers that need to know when a new pack starts.
+type PackStarter interface {
+	StartPack()
+}
+
+// Pop returns a card from the hopper and reports if the hopper is now empty. An empty
+// hopper returns no card, so a pool with no cards resets the draft instead of panicking.
 func (h *NormalHopper) Pop() (Card, bool) {
+	if len(h.Cards) == 0 {
+		return Card{}, true
+	}
 	ret := h.Cards[0]
 	h.Cards = h.Cards[1:]
 	if h.Refillable && len(h.Cards) == 0 {
@@ -82,8 +109,11 @@ func (h *FoilHopper) Pop() (Card, bool) {
 	var ret Card
 	var empty bool
 
-	r := rand.Intn(4)
+	r := h.rng.Intn(4)
 	if r == 3 {
+		if len(h.Cards) == 0 {
+			return Card{}, true
+		}
 		ret = h.Cards[0]
 		h.Cards = h.Cards[1:]
 		empty = len(h.Cards) == 0
@@ -104,6 +134,23 @@ func (h *BasicLandHopper) Pop() (Card, bool) {
 	return ret, false
 }
 
+// Pop returns the next card on the sheet. A SheetHopper is never empty.
+func (h *SheetHopper) Pop() (Card, bool) {
+	if h.cut {
+		h.position = h.rng.Intn(len(h.Sheet))
+		h.cut = false
+	}
+	ret := h.Sheet[h.position]
+	h.position = (h.position + 1) % len(h.Sheet)
+	return ret, false
+}
+
+// StartPack makes the next Pop start a new run at a random spot on the sheet.
+// It's safe to call more than once per pack when the sheet is shared by several slots.
+func (h *SheetHopper) StartPack() {
+	h.cut = true
+}
+
 // Refill refills the hopper from its source cards.
 func (h *NormalHopper) Refill() {
 	for _, v := range h.Source {
@@ -111,7 +158,7 @@ func (h *NormalHopper) Refill() {
 		copiedCard = v // this copies???
 		h.Cards = append(h.Cards, copiedCard)
 	}
-	rand.Shuffle(len(h.Cards), func(i, j int) {
+	h.rng.Shuffle(len(h.Cards), func(i, j int) {
 		h.Cards[i], h.Cards[j] = h.Cards[j], h.Cards[i]
 	})
 }
@@ -123,7 +170,7 @@ func (h *FoilHopper) Refill() {
 		copiedCard = v // this copies???
 		h.Cards = append(h.Cards, copiedCard)
 	}
-	rand.Shuffle(len(h.Cards), func(i, j int) {
+	h.rng.Shuffle(len(h.Cards), func(i, j int) {
 		h.Cards[i], h.Cards[j] = h.Cards[j], h.Cards[i]
 	})
 }
@@ -138,9 +185,12 @@ func (h *BasicLandHopper) Refill() {
 	// no need to shuffle
 }
 
+// Refill does nothing since a print sheet never runs out.
+func (h *SheetHopper) Refill() {}
+
 // MakeNormalHopper creates a NormalHopper.
-func MakeNormalHopper(refillable bool, sources ...[]Card) *NormalHopper {
-	ret := NormalHopper{}
+func MakeNormalHopper(rng *rand.Rand, refillable bool, sources ...[]Card) *NormalHopper {
+	ret := NormalHopper{rng: rng}
 	for _, cardList := range sources {
 		for _, v := range cardList {
 			var copiedCard Card
@@ -154,8 +204,8 @@ func MakeNormalHopper(refillable bool, sources ...[]Card) *NormalHopper {
 }
 
 // MakeFoilHopper creates a FoilHopper.
-func MakeFoilHopper(commonHopper1 *Hopper, commonHopper2 *Hopper, commonHopper3 *Hopper, sources ...[]Card) *FoilHopper {
-	ret := FoilHopper{OtherHoppers: []*Hopper{commonHopper1, commonHopper2, commonHopper3}}
+func MakeFoilHopper(rng *rand.Rand, commonHopper1 *Hopper, commonHopper2 *Hopper, commonHopper3 *Hopper, sources ...[]Card) *FoilHopper {
+	ret := FoilHopper{OtherHoppers: []*Hopper{commonHopper1, commonHopper2, commonHopper3}, rng: rng}
 	for _, cardList := range sources {
 		for _, v := range cardList {
 			var copiedCard Card
@@ -168,6 +218,17 @@ func MakeFoilHopper(commonHopper1 *Hopper, commonHopper2 *Hopper, commonHopper3
 	return &ret
 }
 
+// MakeSheetHopper creates a SheetHopper for cards in print sheet order.
+func MakeSheetHopper(rng *rand.Rand, sheet []Card) *SheetHopper {
+	ret := SheetHopper{rng: rng, cut: true}
+	for _, v := range sheet {
+		var copiedCard Card
+		copiedCard = v // this copies???
+		ret.Sheet = append(ret.Sheet, copiedCard)
+	}
+	return &ret
+}
+
 // MakeBasicLandHopper creates a BasicLandHopper.
 func MakeBasicLandHopper(sources ...[]Card) *BasicLandHopper {
 	ret := BasicLandHopper{}
@@ -181,3 +242,126 @@ func MakeBasicLandHopper(sources ...[]Card) *BasicLandHopper {
 	ret.Refill()
 	return &ret
 }
+
+// splitCards sorts cards by rarity for the legacy hopper types.
+// In dfc mode DFCs are kept apart so only DFC hoppers use them.
+func splitCards(cards []Card, settings *Settings) (CardSet, CardSet, error) {
+	var allCards CardSet
+	var dfcCards CardSet
+
+	for _, card := range cards {
+		var currentSet *CardSet
+		if settings.DfcMode && card.Dfc {
+			currentSet = &dfcCards
+		} else {
+			currentSet = &allCards
+		}
+		currentSet.All = append(currentSet.All, card)
+
+		switch card.Rarity {
+		case "mythic":
+			currentSet.Mythics = append(currentSet.Mythics, card)
+		case "rare":
+			currentSet.Rares = append(currentSet.Rares, card)
+		case "uncommon":
+			currentSet.Uncommons = append(currentSet.Uncommons, card)
+		case "common":
+			currentSet.Commons = append(currentSet.Commons, card)
+		case "basic":
+			currentSet.Basics = append(currentSet.Basics, card)
+		default:
+			return allCards, dfcCards, fmt.Errorf("error with determining rarity for %v", card)
+		}
+	}
+
+	return allCards, dfcCards, nil
+}
+
+// makeLegacyHoppers builds fresh hoppers from the hopper types in older set json files.
+// New set files should describe their packs with pools and slots instead.
+func makeLegacyHoppers(hopdefs []HopperDefinition, allCards *CardSet, dfcCards *CardSet, rng *rand.Rand) ([15]Hopper, error) {
+	var hoppers [15]Hopper
+	if len(hopdefs) != len(hoppers) {
+		return hoppers, fmt.Errorf("packs need exactly %d hoppers, not %d", len(hoppers), len(hopdefs))
+	}
+	for i, hopdef := range hopdefs {
+		err := checkLegacyRefs(i, hopdef)
+		if err != nil {
+			return hoppers, err
+		}
+		switch hopdef.Type {
+		case "RareHopper":
+			hoppers[i] = MakeNormalHopper(rng, false, allCards.Mythics, allCards.Rares, allCards.Rares)
+		case "RareRefillHopper":
+			hoppers[i] = MakeNormalHopper(rng, true, allCards.Mythics, allCards.Rares, allCards.Rares)
+		case "UncommonHopper":
+			hoppers[i] = MakeNormalHopper(rng, false, allCards.Uncommons, allCards.Uncommons)
+		case "UncommonRefillHopper":
+			hoppers[i] = MakeNormalHopper(rng, true, allCards.Uncommons, allCards.Uncommons)
+		case "CommonHopper":
+			hoppers[i] = MakeNormalHopper(rng, false, allCards.Commons, allCards.Commons)
+		case "CommonRefillHopper":
+			hoppers[i] = MakeNormalHopper(rng, true, allCards.Commons, allCards.Commons)
+		case "BasicLandHopper":
+			hoppers[i] = MakeBasicLandHopper(allCards.Basics)
+		case "CubeHopper":
+			hoppers[i] = MakeNormalHopper(rng, false, allCards.All)
+		case "Pointer":
+			hoppers[i] = hoppers[hopdef.Refs[0]]
+		case "DfcHopper":
+			hoppers[i] = MakeNormalHopper(rng, false,
+				dfcCards.Mythics,
+				dfcCards.Rares, dfcCards.Rares,
+				dfcCards.Uncommons, dfcCards.Uncommons, dfcCards.Uncommons,
+				dfcCards.Uncommons, dfcCards.Uncommons, dfcCards.Uncommons,
+				dfcCards.Commons, dfcCards.Commons, dfcCards.Commons, dfcCards.Commons,
+				dfcCards.Commons, dfcCards.Commons, dfcCards.Commons, dfcCards.Commons,
+				dfcCards.Commons, dfcCards.Commons, dfcCards.Commons)
+		case "DfcRefillHopper":
+			hoppers[i] = MakeNormalHopper(rng, true,
+				dfcCards.Mythics,
+				dfcCards.Rares, dfcCards.Rares,
+				dfcCards.Uncommons, dfcCards.Uncommons, dfcCards.Uncommons,
+				dfcCards.Uncommons, dfcCards.Uncommons, dfcCards.Uncommons,
+				dfcCards.Commons, dfcCards.Commons, dfcCards.Commons, dfcCards.Commons,
+				dfcCards.Commons, dfcCards.Commons, dfcCards.Commons, dfcCards.Commons,
+				dfcCards.Commons, dfcCards.Commons, dfcCards.Commons)
+		case "FoilHopper":
+			hoppers[i] = MakeFoilHopper(rng, &hoppers[hopdef.Refs[0]], &hoppers[hopdef.Refs[1]], &hoppers[hopdef.Refs[2]],
+				allCards.Mythics,
+				allCards.Rares, allCards.Rares,
+				allCards.Uncommons, allCards.Uncommons, allCards.Uncommons,
+				allCards.Commons, allCards.Commons, allCards.Commons, allCards.Commons,
+				allCards.Basics, allCards.Basics, allCards.Basics, allCards.Basics)
+		default:
+			return hoppers, fmt.Errorf("hoppers[%d] has unknown hopper type %q", i, hopdef.Type)
+		}
+	}
+	return hoppers, nil
+}
+
+// checkLegacyRefs makes sure a Pointer or FoilHopper refers to other hoppers that exist.
+// A Pointer copies its hopper, so that one has to come first.
+func checkLegacyRefs(i int, hopdef HopperDefinition) error {
+	refs := 0
+	switch hopdef.Type {
+	case "Pointer":
+		refs = 1
+	case "FoilHopper":
+		refs = 3
+	}
+	if len(hopdef.Refs) != refs {
+		return fmt.Errorf("hoppers[%d] (%s) needs %d refs, not %d", i, hopdef.Type, refs, len(hopdef.Refs))
+	}
+	for _, ref := range hopdef.Refs {
+		switch {
+		case ref < 0 || ref >= 15:
+			return fmt.Errorf("hoppers[%d] (%s) ref %d is out of range", i, hopdef.Type, ref)
+		case ref == int64(i):
+			return fmt.Errorf("hoppers[%d] (%s) ref %d points at itself", i, hopdef.Type, ref)
+		case hopdef.Type == "Pointer" && ref > int64(i):
+			return fmt.Errorf("hoppers[%d] (%s) ref %d must point at an earlier hopper", i, hopdef.Type, ref)
+		}
+	}
+	return nil
+}
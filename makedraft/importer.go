@@ -0,0 +1,391 @@
+package makedraft
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ScryfallCard is the part of a card in a scryfall bulk data file that makedraft uses.
+type ScryfallCard struct {
+	ID              string          `json:"id"`
+	Lang            string          `json:"lang"`
+	Name            string          `json:"name"`
+	Set             string          `json:"set"`
+	CollectorNumber string          `json:"collector_number"`
+	Cmc             float64         `json:"cmc"`
+	ColorIdentity   []string        `json:"color_identity"`
+	Colors          *[]string       `json:"colors"`
+	Layout          string          `json:"layout"`
+	TypeLine        string          `json:"type_line"`
+	Rarity          string          `json:"rarity"`
+	ManaCost        string          `json:"mana_cost"`
+	ImageURIs       *ScryfallImages `json:"image_uris"`
+	CardFaces       []ScryfallFace  `json:"card_faces"`
+	MtgoID          int             `json:"mtgo_id"`
+	MtgoFoilID      int             `json:"mtgo_foil_id"`
+}
+
+// ScryfallFace is one face of a ScryfallCard.
+type ScryfallFace struct {
+	Name      string          `json:"name"`
+	ManaCost  string          `json:"mana_cost"`
+	TypeLine  string          `json:"type_line"`
+	Colors    *[]string       `json:"colors"`
+	ImageURIs *ScryfallImages `json:"image_uris"`
+}
+
+// ScryfallImages holds the image urls of a card or face.
+type ScryfallImages struct {
+	Normal string `json:"normal"`
+}
+
+// ScryfallIndex looks up cards from a scryfall bulk data file.
+type ScryfallIndex struct {
+	bySetNumber map[string]*ScryfallCard
+	byName      map[string]*ScryfallCard
+}
+
+// ImportProblem describes an input line that couldn't be imported as is.
+type ImportProblem struct {
+	Line    int
+	Text    string
+	Problem string
+	// Skipped is set if the card was left out of the set.
+	Skipped bool
+}
+
+func (p ImportProblem) String() string {
+	return fmt.Sprintf("line %d: %s (%s)", p.Line, p.Problem, p.Text)
+}
+
+// LoadScryfallBulk reads a scryfall bulk data file, like default-cards.json from
+// https://scryfall.com/docs/api/bulk-data. The file is streamed so it doesn't
+// need to fit in memory twice. Only English cards are kept.
+func LoadScryfallBulk(r io.Reader) (*ScryfallIndex, error) {
+	index := ScryfallIndex{
+		bySetNumber: make(map[string]*ScryfallCard),
+		byName:      make(map[string]*ScryfallCard),
+	}
+	decoder := json.NewDecoder(r)
+	_, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error reading scryfall bulk data: %s", err.Error())
+	}
+	for decoder.More() {
+		var card ScryfallCard
+		err = decoder.Decode(&card)
+		if err != nil {
+			return nil, fmt.Errorf("error reading scryfall bulk data: %s", err.Error())
+		}
+		if card.Lang != "" && card.Lang != "en" {
+			continue
+		}
+		index.add(&card)
+	}
+	return &index, nil
+}
+
+func (index *ScryfallIndex) add(card *ScryfallCard) {
+	index.bySetNumber[setNumberKey(card.Set, card.CollectorNumber)] = card
+	names := []string{card.Name}
+	if len(card.CardFaces) > 0 {
+		names = append(names, card.CardFaces[0].Name)
+	}
+	for _, name := range names {
+		key := strings.ToLower(name)
+		// drafts get exported to MTGO, so prefer printings that exist there.
+		if existing, ok := index.byName[key]; !ok || (existing.MtgoID == 0 && card.MtgoID != 0) {
+			index.byName[key] = card
+		}
+	}
+}
+
+// Len returns how many printings were loaded.
+func (index *ScryfallIndex) Len() int {
+	return len(index.bySetNumber)
+}
+
+// BySetNumber finds a printing by set code and collector number.
+func (index *ScryfallIndex) BySetNumber(set string, number string) *ScryfallCard {
+	return index.bySetNumber[setNumberKey(set, number)]
+}
+
+// ByName finds a printing by its name or the name of its front face.
+func (index *ScryfallIndex) ByName(name string) *ScryfallCard {
+	return index.byName[strings.ToLower(strings.TrimSpace(name))]
+}
+
+func setNumberKey(set string, number string) string {
+	return strings.ToLower(strings.TrimSpace(set)) + "/" + strings.TrimSpace(number)
+}
+
+// ImportCubeCobraCSV builds a cube from a CSV exported from CubeCobra.
+// Columns are found by their header, so the file can be used as downloaded.
+// Cards are matched by set and collector number, or by name if those don't match.
+func ImportCubeCobraCSV(r io.Reader, index *ScryfallIndex) (DraftConfig, []ImportProblem, error) {
+	cfg := DraftConfig{
+		Pools: []PoolDefinition{{Name: "cube", Type: PoolTypeBalanced, Sources: []PoolSource{{}}}},
+		Flags: []string{},
+	}
+	for i := 0; i < 15; i++ {
+		cfg.Slots = append(cfg.Slots, SlotDefinition{Pool: "cube"})
+	}
+
+	records, err := readCSV(r)
+	if err != nil {
+		return cfg, nil, err
+	}
+	if len(records) == 0 {
+		return cfg, nil, fmt.Errorf("the cube csv is empty")
+	}
+	columns := make(map[string]int)
+	for i, header := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+	nameColumn, ok := columns["name"]
+	if !ok {
+		return cfg, nil, fmt.Errorf("the cube csv has no name column")
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var problems []ImportProblem
+	for n, record := range records[1:] {
+		line := n + 2
+		text := strings.Join(record, ",")
+		if strings.EqualFold(field(record, "maybeboard"), "true") {
+			continue
+		}
+		name := field(record, "name")
+		if nameColumn >= len(record) || name == "" {
+			continue
+		}
+
+		card := index.BySetNumber(field(record, "set"), field(record, "collector number"))
+		if card == nil || !strings.EqualFold(card.Name, name) && !strings.EqualFold(frontFace(card), name) {
+			card = index.ByName(name)
+			if card == nil {
+				problems = append(problems, ImportProblem{Line: line, Text: text, Problem: fmt.Sprintf("no card named %q", name), Skipped: true})
+				continue
+			}
+			if field(record, "set") != "" {
+				problems = append(problems, ImportProblem{Line: line, Text: text, Problem: fmt.Sprintf("no printing %s #%s, using %s #%s",
+					field(record, "set"), field(record, "collector number"), card.Set, card.CollectorNumber)})
+			}
+		}
+
+		finish := field(record, "finish")
+		foil := strings.EqualFold(finish, "foil")
+		if !foil && finish != "" && !strings.EqualFold(finish, "non-foil") {
+			problems = append(problems, ImportProblem{Line: line, Text: text, Problem: fmt.Sprintf("unknown finish %q, treating as non-foil", finish)})
+		}
+
+		mtgoID := card.MtgoID
+		if foil {
+			if card.MtgoFoilID != 0 {
+				mtgoID = card.MtgoFoilID
+			} else if mtgoID != 0 {
+				mtgoID++
+			}
+		}
+
+		imported, problem := importCard(card, Foil{Foil: foil}, nil, mtgoID)
+		if problem != "" {
+			problems = append(problems, ImportProblem{Line: line, Text: text, Problem: problem, Skipped: true})
+			continue
+		}
+		if mtgoID == 0 {
+			problems = append(problems, ImportProblem{Line: line, Text: text, Problem: fmt.Sprintf("%s has no mtgo id", card.Name)})
+		}
+		cfg.Cards = append(cfg.Cards, imported)
+	}
+
+	return cfg, problems, nil
+}
+
+// ImportRatingsCSV builds a retail set from a CSV of collector numbers and ratings,
+// one card per line, like `12,3.5`. The packs are laid out like a normal booster.
+func ImportRatingsCSV(r io.Reader, set string, index *ScryfallIndex) (DraftConfig, []ImportProblem, error) {
+	cfg := boosterDraftConfig()
+
+	records, err := readCSV(r)
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	var problems []ImportProblem
+	for n, record := range records {
+		line := n + 1
+		text := strings.Join(record, ",")
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		rating, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			if line == 1 {
+				// a header
+				continue
+			}
+			problems = append(problems, ImportProblem{Line: line, Text: text, Problem: fmt.Sprintf("bad rating %q", record[1]), Skipped: true})
+			continue
+		}
+
+		card := index.BySetNumber(set, record[0])
+		if card == nil {
+			problems = append(problems, ImportProblem{Line: line, Text: text, Problem: fmt.Sprintf("no card %s #%s", set, strings.TrimSpace(record[0])), Skipped: true})
+			continue
+		}
+
+		imported, problem := importCard(card, Foil{FromSlot: true}, &rating, card.MtgoID)
+		if problem != "" {
+			problems = append(problems, ImportProblem{Line: line, Text: text, Problem: problem, Skipped: true})
+			continue
+		}
+		if card.MtgoID == 0 {
+			problems = append(problems, ImportProblem{Line: line, Text: text, Problem: fmt.Sprintf("%s has no mtgo id", card.Name)})
+		}
+		cfg.Cards = append(cfg.Cards, imported)
+	}
+
+	return cfg, problems, nil
+}
+
+// dfcLayouts are the scryfall layouts of cards that are printed double-faced.
+var dfcLayouts = map[string]bool{
+	"transform":       true,
+	"modal_dfc":       true,
+	"meld":            true,
+	"reversible_card": true,
+}
+
+// importCard turns a scryfall card into a Card, or explains why it can't.
+func importCard(card *ScryfallCard, foil Foil, rating *float64, mtgoID int) (Card, string) {
+	data := CardData{
+		Foil: foil,
+		Scryfall: ScryfallData{
+			Cmc:             card.Cmc,
+			ColorIdentity:   card.ColorIdentity,
+			Layout:          card.Layout,
+			Name:            card.Name,
+			TypeLine:        card.TypeLine,
+			CollectorNumber: card.CollectorNumber,
+			Rarity:          card.Rarity,
+			Set:             card.Set,
+			Colors:          card.Colors,
+			ManaCost:        card.ManaCost,
+		},
+		Rating: rating,
+		MtgoID: int64(mtgoID),
+	}
+	if data.Scryfall.ColorIdentity == nil {
+		data.Scryfall.ColorIdentity = []string{}
+	}
+
+	if card.ImageURIs != nil {
+		data.ImageURIs = []string{card.ImageURIs.Normal}
+	} else if len(card.CardFaces) == 2 && card.CardFaces[0].ImageURIs != nil && card.CardFaces[1].ImageURIs != nil {
+		data.ImageURIs = []string{card.CardFaces[0].ImageURIs.Normal, card.CardFaces[1].ImageURIs.Normal}
+	} else {
+		return Card{}, fmt.Sprintf("%s has no image", card.Name)
+	}
+
+	for _, face := range card.CardFaces {
+		data.Scryfall.CardFaces = append(data.Scryfall.CardFaces, CardFace{
+			ManaCost: face.ManaCost,
+			Name:     face.Name,
+			TypeLine: face.TypeLine,
+			Colors:   face.Colors,
+		})
+	}
+
+	var colors []string
+	if card.Colors != nil {
+		colors = *card.Colors
+	} else if len(card.CardFaces) > 0 && card.CardFaces[0].Colors != nil {
+		colors = *card.CardFaces[0].Colors
+	}
+
+	rarity := card.Rarity
+	if strings.Contains(card.TypeLine, "Basic Land") {
+		rarity = "basic"
+	}
+
+	encoded, err := data.Encode()
+	if err != nil {
+		return Card{}, fmt.Sprintf("error encoding %s: %s", card.Name, err.Error())
+	}
+
+	ret := Card{
+		Color:         strings.Join(colors, ""),
+		ColorIdentity: strings.Join(card.ColorIdentity, ""),
+		Dfc:           dfcLayouts[card.Layout],
+		ID:            card.ID,
+		Rarity:        rarity,
+		Data:          encoded,
+	}
+	if rating != nil {
+		ret.Rating = *rating
+	}
+	return ret, ""
+}
+
+// boosterDraftConfig lays packs out like a normal booster: a rare, three uncommons,
+// ten commons, a common that's foil a quarter of the time and a basic land.
+func boosterDraftConfig() DraftConfig {
+	source := func(rarity string, copies int) PoolSource {
+		return PoolSource{Rarity: []string{rarity}, Copies: copies}
+	}
+	cfg := DraftConfig{
+		Pools: []PoolDefinition{
+			{Name: "rares", Sources: []PoolSource{source("mythic", 1), source("rare", 2)}},
+			{Name: "uncommons", Sources: []PoolSource{source("uncommon", 2)}},
+			{Name: "commons_a", Sources: []PoolSource{source("common", 2)}},
+			{Name: "commons_b", Sources: []PoolSource{source("common", 2)}},
+			{Name: "commons_c", Sources: []PoolSource{source("common", 2)}},
+			{Name: "foils", Foil: true, Sources: []PoolSource{
+				source("mythic", 1), source("rare", 2), source("uncommon", 3), source("common", 4), source("basic", 4)}},
+			{Name: "basics", Type: PoolTypeBasic, Sources: []PoolSource{source("basic", 1)}},
+		},
+		Flags: []string{},
+	}
+	slots := []string{"rares", "uncommons", "uncommons", "uncommons",
+		"commons_a", "commons_a", "commons_a", "commons_b", "commons_b", "commons_b",
+		"commons_c", "commons_c", "commons_c"}
+	for _, pool := range slots {
+		cfg.Slots = append(cfg.Slots, SlotDefinition{Pool: pool})
+	}
+	var options []SlotOption
+	for _, pool := range []string{"commons_a", "commons_b", "commons_c", "foils"} {
+		options = append(options, SlotOption{Pool: pool, Weight: 1})
+	}
+	cfg.Slots = append(cfg.Slots, SlotDefinition{Options: options}, SlotDefinition{Pool: "basics"})
+	return cfg
+}
+
+// readCSV reads a whole CSV file, forgiving ragged lines and stray quotes.
+func readCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv: %s", err.Error())
+	}
+	return records, nil
+}
+
+func frontFace(card *ScryfallCard) string {
+	if len(card.CardFaces) > 0 {
+		return card.CardFaces[0].Name
+	}
+	return card.Name
+}
@@ -0,0 +1,390 @@
+// Package makedraft generates the packs for a draft from a set json file.
+//
+// It is used both by the makedraft command and by the server's admin API.
+package makedraft
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GeneratedDraft is the result of generating a draft.
+type GeneratedDraft struct {
+	Packs [24][15]Card
+	// PackSets names the set each pack came from in a mixed draft.
+	PackSets        [24]string
+	Seed            int64
+	DraftAttempts   int
+	PackAttempts    int
+	PackRejections  Rejections
+	DraftRejections Rejections
+}
+
+// Constraints that aren't named after a flag.
+const (
+	ConstraintDuplicateInPack = "duplicate-card-in-pack"
+	ConstraintOutOfCards      = "out-of-cards"
+)
+
+// Rejections counts how many packs or drafts each constraint rejected.
+type Rejections map[string]int
+
+// Rejection is a single entry of Rejections.
+type Rejection struct {
+	Constraint string `json:"constraint"`
+	Count      int    `json:"count"`
+}
+
+// Sorted lists the constraints that rejected the most first.
+func (r Rejections) Sorted() []Rejection {
+	ret := make([]Rejection, 0, len(r))
+	for constraint, count := range r {
+		ret = append(ret, Rejection{Constraint: constraint, Count: count})
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		if ret[a].Count != ret[b].Count {
+			return ret[a].Count > ret[b].Count
+		}
+		return ret[a].Constraint < ret[b].Constraint
+	})
+	return ret
+}
+
+func (r Rejections) String() string {
+	if len(r) == 0 {
+		return "none"
+	}
+	var parts []string
+	for _, rejection := range r.Sorted() {
+		parts = append(parts, fmt.Sprintf("%s %d", rejection.Constraint, rejection.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GenerationError is returned when GenerateDraft gives up before finding a draft that passes.
+type GenerationError struct {
+	Reason          string
+	DraftAttempts   int
+	PackAttempts    int
+	PackRejections  Rejections
+	DraftRejections Rejections
+}
+
+func (e *GenerationError) Error() string {
+	return fmt.Sprintf("gave up generating draft after %d draft attempts and %d pack attempts: %s. packs rejected by: %s. drafts rejected by: %s",
+		e.DraftAttempts, e.PackAttempts, e.Reason, e.PackRejections, e.DraftRejections)
+}
+
+// LoadDraftConfig reads a set json file.
+func LoadDraftConfig(path string) (DraftConfig, error) {
+	var cfg DraftConfig
+	byteValue, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("error opening json file: %s", err.Error())
+	}
+	err = json.Unmarshal(byteValue, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error unmarshalling: %s", err.Error())
+	}
+	return cfg, nil
+}
+
+// FlagArgs splits the flags stored in the set json file into command line arguments.
+func (cfg DraftConfig) FlagArgs() ([]string, error) {
+	if len(cfg.Flags) == 0 {
+		return nil, nil
+	}
+	jsonFlags := strings.Join(cfg.Flags, " ")
+	r := csv.NewReader(strings.NewReader(jsonFlags))
+	r.Comma = ' '
+	fields, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing json flags: %s", err.Error())
+	}
+	var allFlags []string
+	for _, flag := range fields {
+		if flag != "" {
+			allFlags = append(allFlags, flag)
+		}
+	}
+	return allFlags, nil
+}
+
+// GenerateDraft keeps generating packs from the set until it finds a draft
+// where every pack and the draft as a whole pass the constraints in settings.
+// It returns a *GenerationError if the attempt limits, the timeout or the deadline in settings
+// run out first.
+func GenerateDraft(cfg DraftConfig, settings Settings) (GeneratedDraft, error) {
+	var packs [24][15]Card
+	result, err := generatePacks(cfg, settings, packs[:])
+	result.Packs = packs
+	return result, err
+}
+
+// generatePacks fills packs the way GenerateDraft does. The draft level
+// constraints apply to just these packs.
+func generatePacks(cfg DraftConfig, settings Settings, packs [][15]Card) (GeneratedDraft, error) {
+	var result GeneratedDraft
+
+	seed := settings.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	result.Seed = seed
+	result.PackRejections = make(Rejections)
+	result.DraftRejections = make(Rejections)
+
+	start := time.Now()
+	// giveUp checks the limits in settings. The draft limit is only checked once a draft has failed.
+	giveUp := func(draftFailed bool) error {
+		var reason string
+		if draftFailed && settings.MaxDraftAttempts != 0 && result.DraftAttempts >= settings.MaxDraftAttempts {
+			reason = fmt.Sprintf("reached max-draft-attempts=%d", settings.MaxDraftAttempts)
+		} else if settings.MaxPackAttempts != 0 && result.PackAttempts >= settings.MaxPackAttempts {
+			reason = fmt.Sprintf("reached max-pack-attempts=%d", settings.MaxPackAttempts)
+		} else if settings.Timeout != 0 && time.Since(start) > settings.Timeout {
+			reason = fmt.Sprintf("reached timeout=%s", settings.Timeout)
+		} else if !settings.Deadline.IsZero() && time.Now().After(settings.Deadline) {
+			reason = "ran out of time"
+		} else {
+			return nil
+		}
+		return &GenerationError{
+			Reason:          reason,
+			DraftAttempts:   result.DraftAttempts,
+			PackAttempts:    result.PackAttempts,
+			PackRejections:  result.PackRejections,
+			DraftRejections: result.DraftRejections,
+		}
+	}
+
+	infos := makeCardInfos(cfg.Cards)
+	packRules, draftRules, err := makeRules(&cfg, infos, &settings)
+	if err != nil {
+		return result, err
+	}
+
+	makeHoppers := func() ([15]Hopper, error) {
+		return makeSlotHoppers(&cfg, infos, rng)
+	}
+	if len(cfg.Slots) == 0 {
+		allCards, dfcCards, err := splitCards(cfg.Cards, &settings)
+		if err != nil {
+			return result, err
+		}
+		makeHoppers = func() ([15]Hopper, error) {
+			return makeLegacyHoppers(cfg.Hoppers, &allCards, &dfcCards, rng)
+		}
+	}
+
+	for {
+		hoppers, err := makeHoppers()
+		if err != nil {
+			return result, err
+		}
+		resetDraft := false
+		result.DraftAttempts++
+		for i := 0; i < len(packs); { // we'll manually increment i
+			if err = giveUp(false); err != nil {
+				return result, err
+			}
+			result.PackAttempts++
+			for _, hopper := range hoppers {
+				if starter, ok := hopper.(PackStarter); ok {
+					starter.StartPack()
+				}
+			}
+			for j, hopper := range hoppers {
+				var empty bool
+				packs[i][j], empty = hopper.Pop()
+				if empty {
+					resetDraft = true
+					break
+				}
+			}
+
+			if resetDraft {
+				break
+			}
+
+			if settings.Verbose {
+				for _, card := range packs[i] {
+					log.Printf("%s\t%v\t%s", card.Rarity, card.Foil, card.Data)
+				}
+			}
+
+			failed := okPack(packs[i], packRules, &settings)
+			for _, constraint := range failed {
+				result.PackRejections[constraint]++
+			}
+			if len(failed) == 0 {
+				i++
+			}
+		}
+		if resetDraft {
+			result.DraftRejections[ConstraintOutOfCards]++
+		} else {
+			failed := okDraft(packs, draftRules, &settings)
+			for _, constraint := range failed {
+				result.DraftRejections[constraint]++
+			}
+			if len(failed) == 0 {
+				break
+			}
+		}
+
+		if settings.Verbose {
+			log.Printf("RESETTING DRAFT")
+		}
+		if err = giveUp(true); err != nil {
+			return result, err
+		}
+	}
+
+	if settings.Verbose {
+		log.Printf("draft attempts: %d", result.DraftAttempts)
+		log.Printf("pack attempts: %d", result.PackAttempts)
+	}
+
+	return result, nil
+}
+
+// InsertDraft creates a new draft with the given packs and returns its id.
+// Each card is added to the catalog if it isn't there yet.
+func InsertDraft(tx *sql.Tx, name string, packs [24][15]Card) (int64, error) {
+	draftID, packIDs, err := generateEmptyDraft(tx, name)
+	if err != nil {
+		return draftID, err
+	}
+
+	ids := make(map[string]int64)
+	query := `INSERT INTO cards (pack, original_pack, catalog, foil, mtgo, details) VALUES (?, ?, ?, ?, ?, ?)`
+	for i, pack := range packs {
+		for _, card := range pack {
+			packID := packIDs[i]
+			data, err := ParseCardData(card.Data)
+			if err != nil {
+				return draftID, fmt.Errorf("error reading card %s: %s", card.ID, err.Error())
+			}
+			data = data.Resolve(card.Foil)
+			catalogID, err := catalogCard(tx, card.ID, card.Color, card.ColorIdentity, card.Rarity, data, ids)
+			if err != nil {
+				return draftID, err
+			}
+			details, mtgo, err := copyColumns(data)
+			if err != nil {
+				return draftID, fmt.Errorf("error reading card %s: %s", card.ID, err.Error())
+			}
+			_, err = tx.Exec(query, packID, packID, catalogID, data.Foil.Foil, mtgo, details)
+			if err != nil {
+				return draftID, fmt.Errorf("error inserting card: %s", err.Error())
+			}
+		}
+	}
+
+	return draftID, nil
+}
+
+// generateEmptyDraft creates the draft, its seats and its empty packs.
+// It returns the draft id and the ids of the 24 packs that need cards.
+func generateEmptyDraft(tx *sql.Tx, name string) (int64, [24]int64, error) {
+	var packIds [24]int64
+
+	query := `INSERT INTO drafts (name) VALUES (?);`
+	res, err := tx.Exec(query, name)
+	if err != nil {
+		log.Printf("error creating draft: %s", err)
+		return 0, packIds, err
+	}
+
+	draftID, err := res.LastInsertId()
+	if err != nil {
+		log.Printf("could not get draft ID: %s", err)
+		return draftID, packIds, err
+	}
+
+	query = `INSERT INTO seats (position, draft) VALUES (?, ?)`
+	var seatIds [8]int64
+	for i := 0; i < 8; i++ {
+		res, err = tx.Exec(query, i, draftID)
+		if err != nil {
+			log.Printf("could not create seats in draft: %s", err)
+			return draftID, packIds, err
+		}
+		seatIds[i], err = res.LastInsertId()
+		if err != nil {
+			log.Printf("could not finalize seat creation: %s", err)
+			return draftID, packIds, err
+		}
+	}
+
+	query = `INSERT INTO packs (seat, original_seat, round) VALUES (?, ?, ?)`
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 4; j++ {
+			res, err = tx.Exec(query, seatIds[i], seatIds[i], j)
+			if err != nil {
+				log.Printf("error creating packs: %s", err)
+				return draftID, packIds, err
+			}
+			if j != 0 {
+				packIds[(3*i)+(j-1)], err = res.LastInsertId()
+				if err != nil {
+					log.Printf("error creating packs: %s", err)
+					return draftID, packIds, err
+				}
+			}
+		}
+	}
+
+	return draftID, packIds, nil
+}
+
+func stdev(list []float64) float64 {
+	avg := mean(list)
+
+	var sum float64
+	for _, val := range list {
+		sum += math.Pow(val-avg, 2)
+	}
+	return math.Sqrt(sum / float64(len(list)))
+}
+
+func mean(list []float64) float64 {
+	var sum float64
+	for _, val := range list {
+		sum += val
+	}
+	return sum / float64(len(list))
+}
+
+// from https://stackoverflow.com/questions/22688651/golang-how-to-sort-string-or-byte
+// go generics when
+type sortRunes []rune
+
+func (s sortRunes) Less(i, j int) bool {
+	return s[i] < s[j]
+}
+
+func (s sortRunes) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortRunes) Len() int {
+	return len(s)
+}
+
+func stringSort(s string) string {
+	r := []rune(s)
+	sort.Sort(sortRunes(r))
+	return string(r)
+}
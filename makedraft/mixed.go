@@ -0,0 +1,147 @@
+package makedraft
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DraftSet is a set json file loaded for a draft, along with the settings its packs
+// are generated with.
+type DraftSet struct {
+	Name     string
+	Config   DraftConfig
+	Settings Settings
+}
+
+// LoadDraftSet reads a set json file and works out its settings with NewDraftSet.
+// The set is named after the file.
+func LoadDraftSet(path string, flags []string) (DraftSet, error) {
+	cfg, err := LoadDraftConfig(path)
+	if err != nil {
+		return DraftSet{}, err
+	}
+	return NewDraftSet(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), cfg, flags)
+}
+
+// NewDraftSet works out a set's settings: the defaults, then the flags in cfg, then flags.
+// Every card's data is tagged with the set's name as set_name so exports and replays
+// know where it came from.
+func NewDraftSet(name string, cfg DraftConfig, flags []string) (DraftSet, error) {
+	set := DraftSet{
+		Name:     name,
+		Config:   cfg,
+		Settings: DefaultSettings(),
+	}
+
+	jsonFlags, err := set.Config.FlagArgs()
+	if err != nil {
+		return set, err
+	}
+	err = set.Settings.ApplyFlags(append(jsonFlags, flags...))
+	if err != nil {
+		return set, fmt.Errorf("error parsing flags for %s: %s", set.Name, err.Error())
+	}
+
+	// copy the cards so cfg's own cards aren't tagged.
+	set.Config.Cards = append([]Card(nil), cfg.Cards...)
+	for i := range set.Config.Cards {
+		set.Config.Cards[i].Data, err = tagCardData(set.Config.Cards[i].Data, set.Name)
+		if err != nil {
+			return set, fmt.Errorf("error tagging card %s: %s", set.Config.Cards[i].ID, err.Error())
+		}
+	}
+
+	return set, nil
+}
+
+// tagCardData adds set_name to a card's json data, leaving everything else,
+// including the FOIL_STATUS placeholder, as it was.
+func tagCardData(data string, setName string) (string, error) {
+	cardData, err := ParseCardData(data)
+	if err != nil {
+		return data, err
+	}
+	cardData.SetName = setName
+	return cardData.Encode()
+}
+
+// MixedDraft describes a draft whose packs come from more than one set.
+type MixedDraft struct {
+	Sets []DraftSet
+	// Rounds has the index into Sets of the set used for each of the 3 rounds.
+	// If it's empty, this is a chaos draft and every pack comes from a random set.
+	Rounds []int
+}
+
+// GenerateMixedDraft generates a draft whose packs come from several sets.
+// Each set generates all of its packs at once with its own hoppers and settings,
+// so its draft level constraints apply across just its packs.
+func GenerateMixedDraft(mixed MixedDraft, seed int64) (GeneratedDraft, error) {
+	var result GeneratedDraft
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	result.Seed = seed
+	result.PackRejections = make(Rejections)
+	result.DraftRejections = make(Rejections)
+
+	if len(mixed.Sets) == 0 {
+		return result, fmt.Errorf("a mixed draft needs at least one set")
+	}
+
+	// InsertDraft puts pack i in round i%3+1.
+	var packSets [24]int
+	if len(mixed.Rounds) > 0 {
+		if len(mixed.Rounds) != 3 {
+			return result, fmt.Errorf("a mixed draft needs a set for each of 3 rounds, not %d", len(mixed.Rounds))
+		}
+		for _, set := range mixed.Rounds {
+			if set < 0 || set >= len(mixed.Sets) {
+				return result, fmt.Errorf("round set %d is out of range", set)
+			}
+		}
+		for i := range packSets {
+			packSets[i] = mixed.Rounds[i%3]
+		}
+	} else {
+		rng := rand.New(rand.NewSource(seed))
+		for i := range packSets {
+			packSets[i] = rng.Intn(len(mixed.Sets))
+		}
+	}
+
+	for k, set := range mixed.Sets {
+		var indexes []int
+		for i, packSet := range packSets {
+			if packSet == k {
+				indexes = append(indexes, i)
+			}
+		}
+		if len(indexes) == 0 {
+			continue
+		}
+
+		settings := set.Settings
+		settings.Seed = seed + int64(k) + 1
+		packs := make([][15]Card, len(indexes))
+		generated, err := generatePacks(set.Config, settings, packs)
+		result.DraftAttempts += generated.DraftAttempts
+		result.PackAttempts += generated.PackAttempts
+		addRejections(result.PackRejections, generated.PackRejections)
+		addRejections(result.DraftRejections, generated.DraftRejections)
+		if err != nil {
+			return result, fmt.Errorf("error generating %s packs: %s", set.Name, err.Error())
+		}
+
+		for j, i := range indexes {
+			result.Packs[i] = packs[j]
+			result.PackSets[i] = set.Name
+		}
+	}
+
+	return result, nil
+}
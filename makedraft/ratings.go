@@ -0,0 +1,293 @@
+package makedraft
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// PickChoice is one recorded pick: the card that was taken and the cards it was taken
+// over, the ones still in the pack. Cards are catalog ids.
+type PickChoice struct {
+	Picked int64
+	Passed []int64
+}
+
+// CardStrength is a card's fitted strength. Strength is the log of its Luce choice weight,
+// so a card with a strength one higher than another is taken over it e times as often.
+type CardStrength struct {
+	CatalogID  int64
+	ScryfallID string
+	Set        string
+	Number     string
+	Name       string
+	Strength   float64
+	Picks      int
+	Seen       int
+}
+
+// RatingChange is a card whose rating was refitted.
+type RatingChange struct {
+	ID    string
+	Name  string
+	Old   float64
+	New   float64
+	Picks int
+	Seen  int
+}
+
+// LoadPickChoices rebuilds every pick made in the given drafts, or in every draft if
+// there are none, from the original packs and the order the picks were made in.
+// Only cards in the catalog are known, so picks of other cards are skipped and counted.
+func LoadPickChoices(tx *sql.Tx, draftIDs []int64) ([]PickChoice, map[int64]*CardStrength, int, error) {
+	var packsWhere, eventsWhere string
+	var args []interface{}
+	if len(draftIDs) > 0 {
+		in := `in (?` + strings.Repeat(`, ?`, len(draftIDs)-1) + `)`
+		packsWhere = `where seats.draft ` + in
+		eventsWhere = `where events.draft ` + in
+		for _, id := range draftIDs {
+			args = append(args, id)
+		}
+	}
+
+	query := `select
+                    cards.id,
+                    cards.original_pack,
+                    catalog.id,
+                    catalog.scryfall_id,
+                    catalog.edition,
+                    catalog.number,
+                    catalog.name
+                  from cards
+                  join catalog on catalog.id = cards.catalog
+                  join packs on packs.id = cards.original_pack
+                  join seats on seats.id = packs.original_seat
+                  ` + packsWhere
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("error reading packs: %s", err.Error())
+	}
+	cardCatalog := make(map[int64]int64)
+	packs := make(map[int64][]int64)
+	cards := make(map[int64]*CardStrength)
+	for rows.Next() {
+		var cardID, packID int64
+		var card CardStrength
+		var scryfallID sql.NullString
+		err = rows.Scan(&cardID, &packID, &card.CatalogID, &scryfallID, &card.Set, &card.Number, &card.Name)
+		if err != nil {
+			rows.Close()
+			return nil, nil, 0, fmt.Errorf("error reading packs: %s", err.Error())
+		}
+		card.ScryfallID = scryfallID.String
+		cardCatalog[cardID] = card.CatalogID
+		packs[packID] = append(packs[packID], cardID)
+		if cards[card.CatalogID] == nil {
+			cards[card.CatalogID] = &card
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, nil, 0, err
+	}
+
+	query = `select
+                   events.card1,
+                   events.card2,
+                   cards.original_pack
+                 from events
+                 join cards on cards.id = events.card1
+                 ` + eventsWhere + `
+                 order by events.id`
+	rows, err = tx.Query(query, args...)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("error reading picks: %s", err.Error())
+	}
+	defer rows.Close()
+
+	var choices []PickChoice
+	skipped := 0
+	taken := make(map[int64]bool)
+	for rows.Next() {
+		var card1, packID int64
+		var card2 sql.NullInt64
+		err = rows.Scan(&card1, &card2, &packID)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("error reading picks: %s", err.Error())
+		}
+
+		choice := PickChoice{Picked: cardCatalog[card1]}
+		known := choice.Picked != 0
+		for _, cardID := range packs[packID] {
+			if taken[cardID] || cardID == card1 || (card2.Valid && cardID == card2.Int64) || cardCatalog[cardID] == 0 {
+				continue
+			}
+			choice.Passed = append(choice.Passed, cardCatalog[cardID])
+		}
+		taken[card1] = true
+		if card2.Valid {
+			taken[card2.Int64] = true
+		}
+
+		if !known {
+			skipped++
+			continue
+		}
+		choices = append(choices, choice)
+	}
+	return choices, cards, skipped, rows.Err()
+}
+
+// FitCardStrengths fits a strength to every card from the picks, with the MM algorithm
+// for the Luce choice model (Bradley-Terry for picks out of more than two cards).
+// Every card also gets one win and one loss against an imaginary card of strength 0,
+// so cards that are never picked, or always picked, still get a finite strength.
+func FitCardStrengths(choices []PickChoice, cards map[int64]*CardStrength, iterations int) {
+	weights := make(map[int64]float64)
+	wins := make(map[int64]float64)
+	for _, choice := range choices {
+		if len(choice.Passed) == 0 {
+			// the last card in a pack isn't chosen over anything.
+			continue
+		}
+		wins[choice.Picked]++
+		cards[choice.Picked].Picks++
+		cards[choice.Picked].Seen++
+		for _, id := range choice.Passed {
+			if cards[id] != nil {
+				cards[id].Seen++
+			}
+		}
+	}
+	for id := range cards {
+		weights[id] = 1
+	}
+
+	for i := 0; i < iterations; i++ {
+		denominators := make(map[int64]float64)
+		for _, choice := range choices {
+			if len(choice.Passed) == 0 {
+				continue
+			}
+			total := weights[choice.Picked]
+			for _, id := range choice.Passed {
+				total += weights[id]
+			}
+			denominators[choice.Picked] += 1 / total
+			for _, id := range choice.Passed {
+				denominators[id] += 1 / total
+			}
+		}
+
+		change := 0.0
+		for id, weight := range weights {
+			next := (wins[id] + 1) / (denominators[id] + 2/(weight+1))
+			change = math.Max(change, math.Abs(math.Log(next)-math.Log(weight)))
+			weights[id] = next
+		}
+		if change < 1e-6 {
+			break
+		}
+	}
+
+	for id, card := range cards {
+		card.Strength = math.Log(weights[id])
+	}
+}
+
+// ApplyCardStrengths rewrites the ratings of the set's cards that were seen in at least
+// minSeen picks. Strengths are scaled to have the same mean and spread as those cards'
+// old ratings, kept within their old range, and rounded to step unless it's 0.
+// Sets without ratings yet get ratings from 0 to 5. Ratings in card data are updated too.
+func ApplyCardStrengths(cfg *DraftConfig, cards map[int64]*CardStrength, minSeen int, step float64) ([]RatingChange, error) {
+	byKey := make(map[string]*CardStrength)
+	for _, card := range cards {
+		if card.ScryfallID != "" {
+			byKey[card.ScryfallID] = card
+		}
+		key := card.Set + "/" + card.Number
+		if byKey[key] == nil {
+			byKey[key] = card
+		}
+	}
+
+	var fitted []int
+	var strengths []*CardStrength
+	for i, card := range cfg.Cards {
+		strength := byKey[card.ID]
+		if strength == nil {
+			info := parseCardInfo(card)
+			strength = byKey[info.Set+"/"+info.CollectorNumber]
+		}
+		if strength == nil || strength.Seen < minSeen {
+			continue
+		}
+		fitted = append(fitted, i)
+		strengths = append(strengths, strength)
+	}
+	if len(fitted) == 0 {
+		return nil, nil
+	}
+
+	var oldRatings, newStrengths []float64
+	for j, i := range fitted {
+		oldRatings = append(oldRatings, cfg.Cards[i].Rating)
+		newStrengths = append(newStrengths, strengths[j].Strength)
+	}
+	oldMean, oldStdev := mean(oldRatings), stdev(oldRatings)
+	low, high := oldRatings[0], oldRatings[0]
+	for _, rating := range oldRatings {
+		low = math.Min(low, rating)
+		high = math.Max(high, rating)
+	}
+	if oldStdev == 0 {
+		oldMean, oldStdev, low, high = 2.5, 1, 0, 5
+	}
+	strengthMean, strengthStdev := mean(newStrengths), stdev(newStrengths)
+
+	var changes []RatingChange
+	for j, i := range fitted {
+		rating := oldMean
+		if strengthStdev > 0 {
+			rating += (strengths[j].Strength - strengthMean) / strengthStdev * oldStdev
+		}
+		rating = math.Max(low, math.Min(high, rating))
+		if step > 0 {
+			rating = math.Round(rating/step) * step
+		}
+
+		card := &cfg.Cards[i]
+		if rating == card.Rating {
+			continue
+		}
+		changes = append(changes, RatingChange{
+			ID:    card.ID,
+			Name:  CardName(*card),
+			Old:   card.Rating,
+			New:   rating,
+			Picks: strengths[j].Picks,
+			Seen:  strengths[j].Seen,
+		})
+		card.Rating = rating
+
+		data, err := ParseCardData(card.Data)
+		if err != nil {
+			return changes, fmt.Errorf("error reading card %s: %s", card.ID, err.Error())
+		}
+		if data.Rating != nil {
+			data.Rating = &rating
+			card.Data, err = data.Encode()
+			if err != nil {
+				return changes, fmt.Errorf("error writing card %s: %s", card.ID, err.Error())
+			}
+		}
+	}
+
+	sort.SliceStable(changes, func(a, b int) bool {
+		return math.Abs(changes[a].New-changes[a].Old) > math.Abs(changes[b].New-changes[b].Old)
+	})
+	return changes, nil
+}
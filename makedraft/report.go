@@ -0,0 +1,337 @@
+package makedraft
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"sort"
+)
+
+// Report summarizes a generated draft so it can be reviewed before it's committed.
+// Generating again with the same settings and Seed reproduces the same draft.
+// PackRejections and DraftRejections show which constraints were hardest to satisfy.
+type Report struct {
+	Name            string            `json:"name"`
+	Seed            int64             `json:"seed"`
+	DraftAttempts   int               `json:"draftAttempts"`
+	PackAttempts    int               `json:"packAttempts"`
+	PackRejections  []Rejection       `json:"packRejections"`
+	DraftRejections []Rejection       `json:"draftRejections"`
+	Draft           DistributionStats `json:"draft"`
+	Packs           []PackReport      `json:"packs"`
+	Duplicates      []DuplicateReport `json:"duplicates"`
+}
+
+// PackReport describes a single pack in a Report.
+type PackReport struct {
+	Seat  int               `json:"seat"`
+	Round int               `json:"round"`
+	Set   string            `json:"set,omitempty"`
+	Cards []ReportCard      `json:"cards"`
+	Stats DistributionStats `json:"stats"`
+}
+
+// ReportCard is a card as it appears in a Report.
+type ReportCard struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	Rarity        string  `json:"rarity"`
+	Foil          bool    `json:"foil"`
+	Color         string  `json:"color"`
+	ColorIdentity string  `json:"colorIdentity"`
+	Rating        float64 `json:"rating"`
+}
+
+// DistributionStats describes the makeup of a pack or of the whole draft.
+// The common stats are computed the same way the pack and draft constraints compute them.
+type DistributionStats struct {
+	Rarities                 map[string]int `json:"rarities"`
+	Foils                    int            `json:"foils"`
+	Colors                   map[string]int `json:"colors"`
+	ColorIdentities          map[string]int `json:"colorIdentities"`
+	CommonColors             map[string]int `json:"commonColors"`
+	CommonColorIdentities    map[string]int `json:"commonColorIdentities"`
+	CommonColorStdev         float64        `json:"commonColorStdev"`
+	CommonColorIdentityStdev float64        `json:"commonColorIdentityStdev"`
+	CommonRatingMean         float64        `json:"commonRatingMean"`
+}
+
+// DuplicateReport describes a card that shows up more than once in the draft.
+type DuplicateReport struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Rarity string `json:"rarity"`
+	Count  int    `json:"count"`
+	Max    int    `json:"max"`
+	Over   bool   `json:"over"`
+}
+
+// BuildReport summarizes a generated draft.
+func BuildReport(draft GeneratedDraft, settings Settings) Report {
+	report := Report{
+		Name:          settings.Name,
+		Seed:          draft.Seed,
+		DraftAttempts: draft.DraftAttempts,
+		PackAttempts:  draft.PackAttempts,
+
+		PackRejections:  draft.PackRejections.Sorted(),
+		DraftRejections: draft.DraftRejections.Sorted(),
+	}
+
+	var all []Card
+	counts := make(map[string]int)
+	var order []Card
+	for i, pack := range draft.Packs {
+		packReport := PackReport{
+			// InsertDraft puts pack i in seat i/3, round i%3+1.
+			Seat:  i / 3,
+			Round: i%3 + 1,
+			Set:   draft.PackSets[i],
+			Stats: distribution(pack[:], &settings),
+		}
+		for _, card := range pack {
+			packReport.Cards = append(packReport.Cards, reportCard(card))
+			all = append(all, card)
+			if counts[card.ID] == 0 {
+				order = append(order, card)
+			}
+			counts[card.ID]++
+		}
+		sort.SliceStable(packReport.Cards, func(a, b int) bool {
+			return rarityOrder[packReport.Cards[a].Rarity] < rarityOrder[packReport.Cards[b].Rarity]
+		})
+		report.Packs = append(report.Packs, packReport)
+	}
+	report.Draft = distribution(all, &settings)
+
+	for _, card := range order {
+		if counts[card.ID] < 2 {
+			continue
+		}
+		max := maxCopies(card.Rarity, &settings)
+		report.Duplicates = append(report.Duplicates, DuplicateReport{
+			ID:     card.ID,
+			Name:   CardName(card),
+			Rarity: card.Rarity,
+			Count:  counts[card.ID],
+			Max:    max,
+			Over:   max != 0 && counts[card.ID] > max,
+		})
+	}
+	sort.SliceStable(report.Duplicates, func(a, b int) bool {
+		return report.Duplicates[a].Count > report.Duplicates[b].Count
+	})
+
+	return report
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteHTML writes the report as a standalone html page.
+func (r Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+// rarityOrder sorts cards in a pack the way they're usually listed.
+var rarityOrder = map[string]int{
+	"mythic":   0,
+	"rare":     1,
+	"uncommon": 2,
+	"common":   3,
+	"basic":    4,
+}
+
+// maxCopies returns the max-* limit that applies to a rarity. 0 means no limit.
+func maxCopies(rarity string, settings *Settings) int {
+	switch rarity {
+	case "mythic":
+		return settings.MaxMythic
+	case "rare":
+		return settings.MaxRare
+	case "uncommon":
+		return settings.MaxUncommon
+	case "common":
+		return settings.MaxCommon
+	}
+	return 0
+}
+
+// distribution computes DistributionStats for a group of cards.
+func distribution(cards []Card, settings *Settings) DistributionStats {
+	stats := DistributionStats{
+		Rarities:              make(map[string]int),
+		Colors:                make(map[string]int),
+		ColorIdentities:       make(map[string]int),
+		CommonColors:          make(map[string]int),
+		CommonColorIdentities: make(map[string]int),
+	}
+	var ratings []float64
+	for _, card := range cards {
+		stats.Rarities[card.Rarity]++
+		if card.Foil {
+			stats.Foils++
+		}
+		countColors(stats.Colors, card.Color)
+		countColors(stats.ColorIdentities, card.ColorIdentity)
+		if card.Rarity == "common" && !(card.Foil || (settings.DfcMode && card.Dfc)) {
+			for _, color := range card.Color {
+				stats.CommonColors[string(color)]++
+			}
+			for _, color := range card.ColorIdentity {
+				stats.CommonColorIdentities[string(color)]++
+			}
+			ratings = append(ratings, card.Rating)
+		}
+	}
+	stats.CommonColorStdev = stdev(countValues(stats.CommonColors))
+	stats.CommonColorIdentityStdev = stdev(countValues(stats.CommonColorIdentities))
+	if len(ratings) > 0 {
+		stats.CommonRatingMean = mean(ratings)
+	}
+	return stats
+}
+
+// countColors counts each color in colors, or C for colorless.
+func countColors(hash map[string]int, colors string) {
+	if colors == "" {
+		hash["C"]++
+		return
+	}
+	for _, color := range colors {
+		hash[string(color)]++
+	}
+}
+
+// countValues turns a color count into the list stdev expects.
+func countValues(hash map[string]int) []float64 {
+	var values []float64
+	for _, v := range hash {
+		values = append(values, float64(v))
+	}
+	if len(values) == 0 {
+		return []float64{0}
+	}
+	return values
+}
+
+// reportCard converts a card for a Report.
+func reportCard(card Card) ReportCard {
+	return ReportCard{
+		ID:            card.ID,
+		Name:          CardName(card),
+		Rarity:        card.Rarity,
+		Foil:          card.Foil,
+		Color:         card.Color,
+		ColorIdentity: card.ColorIdentity,
+		Rating:        card.Rating,
+	}
+}
+
+// parseCardInfo digs the card's scryfall info out of its json data.
+func parseCardInfo(card Card) ScryfallData {
+	data, _ := ParseCardData(card.Data)
+	return data.Scryfall
+}
+
+// cardInfos is the scryfall info of a set's cards by id, parsed once so rules and
+// hoppers that look at every card on every attempt don't parse card data each time.
+type cardInfos map[string]ScryfallData
+
+// makeCardInfos parses the scryfall info of every card.
+func makeCardInfos(cards []Card) cardInfos {
+	infos := make(cardInfos)
+	for _, card := range cards {
+		if _, ok := infos[card.ID]; !ok {
+			infos[card.ID] = parseCardInfo(card)
+		}
+	}
+	return infos
+}
+
+// get returns a card's scryfall info, parsing it if the card isn't one of the set's.
+func (infos cardInfos) get(card Card) ScryfallData {
+	if info, ok := infos[card.ID]; ok {
+		return info
+	}
+	return parseCardInfo(card)
+}
+
+// CardName digs the card's name out of its json data.
+func CardName(card Card) string {
+	name := parseCardInfo(card).Name
+	if name == "" {
+		return card.ID
+	}
+	return name
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!doctype html>
+<html>
+<head>
+  <title>{{ .Name }} dry run</title>
+  <style>
+    body { font-family: sans-serif; }
+    table { border-collapse: collapse; margin-bottom: 1em; }
+    td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+    .foil { font-style: italic; }
+    .over { color: #c00; font-weight: bold; }
+    .pack { display: inline-block; vertical-align: top; margin: 0 1em 1em 0; }
+  </style>
+</head>
+<body>
+<h1>{{ .Name }}</h1>
+<p>seed {{ .Seed }}, {{ .DraftAttempts }} draft attempts, {{ .PackAttempts }} pack attempts</p>
+
+<h2>Rejections</h2>
+<table>
+  <tr><th>constraint</th><th>packs rejected</th></tr>
+  {{ range .PackRejections }}<tr><td>{{ .Constraint }}</td><td>{{ .Count }}</td></tr>{{ end }}
+</table>
+<table>
+  <tr><th>constraint</th><th>drafts rejected</th></tr>
+  {{ range .DraftRejections }}<tr><td>{{ .Constraint }}</td><td>{{ .Count }}</td></tr>{{ end }}
+</table>
+
+<h2>Whole draft</h2>
+{{ template "stats" .Draft }}
+
+<h2>Duplicates</h2>
+<table>
+  <tr><th>card</th><th>rarity</th><th>copies</th><th>max</th></tr>
+  {{ range .Duplicates }}
+  <tr{{ if .Over }} class="over"{{ end }}><td>{{ .Name }}</td><td>{{ .Rarity }}</td><td>{{ .Count }}</td><td>{{ if .Max }}{{ .Max }}{{ else }}-{{ end }}</td></tr>
+  {{ end }}
+</table>
+
+<h2>Packs</h2>
+{{ range .Packs }}
+<div class="pack">
+  <h3>seat {{ .Seat }}, round {{ .Round }}{{ if .Set }}, {{ .Set }}{{ end }}</h3>
+  <table>
+    <tr><th>card</th><th>rarity</th><th>color</th><th>identity</th><th>rating</th></tr>
+    {{ range .Cards }}
+    <tr{{ if .Foil }} class="foil"{{ end }}><td>{{ .Name }}{{ if .Foil }} (foil){{ end }}</td><td>{{ .Rarity }}</td><td>{{ .Color }}</td><td>{{ .ColorIdentity }}</td><td>{{ .Rating }}</td></tr>
+    {{ end }}
+  </table>
+  {{ template "stats" .Stats }}
+</div>
+{{ end }}
+</body>
+</html>
+{{ define "stats" }}
+<table>
+  <tr><th>rarities</th><td>{{ range $k, $v := .Rarities }}{{ $k }}: {{ $v }} {{ end }}</td></tr>
+  <tr><th>foils</th><td>{{ .Foils }}</td></tr>
+  <tr><th>colors</th><td>{{ range $k, $v := .Colors }}{{ $k }}: {{ $v }} {{ end }}</td></tr>
+  <tr><th>color identities</th><td>{{ range $k, $v := .ColorIdentities }}{{ $k }}: {{ $v }} {{ end }}</td></tr>
+  <tr><th>common colors</th><td>{{ range $k, $v := .CommonColors }}{{ $k }}: {{ $v }} {{ end }}(stdev {{ printf "%.2f" .CommonColorStdev }})</td></tr>
+  <tr><th>common color identities</th><td>{{ range $k, $v := .CommonColorIdentities }}{{ $k }}: {{ $v }} {{ end }}(stdev {{ printf "%.2f" .CommonColorIdentityStdev }})</td></tr>
+  <tr><th>common rating mean</th><td>{{ printf "%.2f" .CommonRatingMean }}</td></tr>
+</table>
+{{ end }}
+`))
@@ -0,0 +1,581 @@
+package makedraft
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Rule is a named check that generated packs or drafts must pass.
+// Rejections are counted by Name.
+type Rule interface {
+	Name() string
+}
+
+// PackRule checks every pack as it's generated.
+type PackRule interface {
+	Rule
+	CheckPack(pack []Card, settings *Settings) bool
+}
+
+// DraftRule checks all the cards in a draft once every pack has passed.
+type DraftRule interface {
+	Rule
+	CheckDraft(cards []Card, settings *Settings) bool
+}
+
+// RuleDefinition is part of DraftConfig and adds a rule on top of the ones set by flags.
+// Which fields are used depends on Rule.
+type RuleDefinition struct {
+	Rule   string   `json:"rule"`
+	Name   string   `json:"name,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	MinCmc *float64 `json:"min_cmc,omitempty"`
+	MaxCmc *float64 `json:"max_cmc,omitempty"`
+	Rarity []string `json:"rarity,omitempty"`
+	Cards  []string `json:"cards,omitempty"`
+}
+
+// Rule types. The ones that match a flag behave exactly like it.
+const (
+	RuleDuplicateInPack                  = ConstraintDuplicateInPack
+	RuleUniquePerPack                    = "unique-per-pack"
+	RuleDuplicateThreeColorUncommons     = "abort-duplicate-three-color-identity-uncommons"
+	RuleMissingCommonColor               = "abort-missing-common-color"
+	RuleMissingCommonColorIdentity       = "abort-missing-common-color-identity"
+	RulePackCommonColorStdevMax          = "pack-common-color-stdev-max"
+	RulePackCommonColorIdentityStdevMax  = "pack-common-color-identity-stdev-max"
+	RulePackCommonRatingMin              = "pack-common-rating-min"
+	RulePackCommonRatingMax              = "pack-common-rating-max"
+	RuleCreatureCount                    = "creature-count"
+	RuleManaCurve                        = "mana-curve"
+	RuleMaxCopies                        = "max-copies"
+	RuleDraftCommonColorStdevMax         = "draft-common-color-stdev-max"
+	RuleDraftCommonColorIdentityStdevMax = "draft-common-color-identity-stdev-max"
+	RuleSignposts                        = "signposts"
+	RulePackColorStdevMax                = "pack-color-stdev-max"
+	RulePackColorIdentityStdevMax        = "pack-color-identity-stdev-max"
+	RuleMulticolorCount                  = "multicolor-count"
+	RuleColorlessCount                   = "colorless-count"
+	RuleLandCount                        = "land-count"
+)
+
+// duplicateRule rejects packs with two copies of a card, ignoring foils, DFCs in
+// dfc mode and, unless all is set, nothing else. With all set only basics are ignored.
+type duplicateRule struct {
+	name string
+	all  bool
+}
+
+func (r *duplicateRule) Name() string { return r.name }
+
+func (r *duplicateRule) CheckPack(pack []Card, settings *Settings) bool {
+	cardHash := make(map[string]int)
+	for _, card := range pack {
+		if r.all {
+			if card.Rarity == "basic" {
+				continue
+			}
+		} else if card.Foil || (settings.DfcMode && card.Dfc) {
+			continue
+		}
+		cardHash[card.ID]++
+		if cardHash[card.ID] > 1 {
+			if settings.Verbose {
+				log.Printf("found duplicated card %s", card.ID)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// threeColorUncommonsRule allows only one uncommon of a color identity triplet per pack,
+// and never three uncommons of the same color identity.
+type threeColorUncommonsRule struct {
+	name string
+}
+
+func (r *threeColorUncommonsRule) Name() string { return r.name }
+
+func (r *threeColorUncommonsRule) CheckPack(pack []Card, settings *Settings) bool {
+	uncommonColorIdentities := make(map[string]int)
+	for _, card := range pack {
+		if card.Rarity != "uncommon" || card.Foil || (settings.DfcMode && card.Dfc) {
+			continue
+		}
+		sortedColor := stringSort(card.ColorIdentity)
+		uncommonColorIdentities[sortedColor]++
+		if uncommonColorIdentities[sortedColor] > 1 && len(sortedColor) == 3 {
+			if settings.Verbose {
+				log.Printf("found more than one uncommon %s card", sortedColor)
+			}
+			return false
+		}
+		if uncommonColorIdentities[sortedColor] >= 3 {
+			if settings.Verbose {
+				log.Printf("all uncommons are %s", sortedColor)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// missingColorRule requires every color among the colors, or color identities, of commons.
+type missingColorRule struct {
+	name     string
+	identity bool
+}
+
+func (r *missingColorRule) Name() string { return r.name }
+
+func (r *missingColorRule) CheckPack(pack []Card, settings *Settings) bool {
+	if len(commonColorCounts(pack, r.identity, settings)) != 5 {
+		if settings.Verbose {
+			log.Printf("a color is missing among commons")
+		}
+		return false
+	}
+	return true
+}
+
+// colorStdevRule limits the standard deviation of colors, or color identities, among commons.
+// Missing colors count as zero when the matching abort-missing flag is set.
+// With all set it looks at every card but basics instead, and missing colors always count as zero.
+type colorStdevRule struct {
+	name     string
+	identity bool
+	all      bool
+	max      float64
+}
+
+func (r *colorStdevRule) Name() string { return r.name }
+
+func (r *colorStdevRule) CheckPack(pack []Card, settings *Settings) bool {
+	if r.all {
+		return r.check(allColorCounts(pack, r.identity), settings)
+	}
+	colors := commonColorCounts(pack, r.identity, settings)
+	pad := settings.AbortMissingCommonColor
+	if r.identity {
+		pad = settings.AbortMissingCommonColorIdentity
+	}
+	for pad && len(colors) < 5 {
+		colors = append(colors, 0)
+	}
+	return r.check(colors, settings)
+}
+
+func (r *colorStdevRule) CheckDraft(cards []Card, settings *Settings) bool {
+	if r.all {
+		return r.check(allColorCounts(cards, r.identity), settings)
+	}
+	return r.check(commonColorCounts(cards, r.identity, settings), settings)
+}
+
+func (r *colorStdevRule) check(colors []float64, settings *Settings) bool {
+	colorStdev := stdev(colors)
+	if settings.Verbose {
+		log.Printf("%s:\t%f", r.name, colorStdev)
+	}
+	return !(colorStdev > r.max)
+}
+
+// ratingRule bounds the mean rating of commons in a pack.
+type ratingRule struct {
+	name string
+	min  float64
+	max  float64
+}
+
+func (r *ratingRule) Name() string { return r.name }
+
+func (r *ratingRule) CheckPack(pack []Card, settings *Settings) bool {
+	var ratings []float64
+	for _, card := range countedCommons(pack, settings) {
+		ratings = append(ratings, card.Rating)
+	}
+	ratingMean := mean(ratings)
+	if settings.Verbose {
+		log.Printf("rating mean:\t%f", ratingMean)
+	}
+	if r.max != 0 && ratingMean > r.max {
+		return false
+	}
+	if r.min != 0 && ratingMean < r.min {
+		return false
+	}
+	return true
+}
+
+// countRule bounds how many cards in a pack match.
+type countRule struct {
+	name    string
+	min     *float64
+	max     *float64
+	rarity  []string
+	infos   cardInfos
+	matches func(card Card, info ScryfallData) bool
+}
+
+func (r *countRule) Name() string { return r.name }
+
+func (r *countRule) CheckPack(pack []Card, settings *Settings) bool {
+	count := 0
+	for _, card := range pack {
+		if len(r.rarity) > 0 && !containsString(r.rarity, card.Rarity) {
+			continue
+		}
+		if r.matches(card, r.infos.get(card)) {
+			count++
+		}
+	}
+	if settings.Verbose {
+		log.Printf("%s:\t%d", r.name, count)
+	}
+	if r.min != nil && float64(count) < *r.min {
+		return false
+	}
+	if r.max != nil && float64(count) > *r.max {
+		return false
+	}
+	return true
+}
+
+// maxCopiesRule limits how many copies of each card of some rarities a draft may have.
+type maxCopiesRule struct {
+	name   string
+	rarity []string
+	max    int
+}
+
+func (r *maxCopiesRule) Name() string { return r.name }
+
+func (r *maxCopiesRule) CheckDraft(cards []Card, settings *Settings) bool {
+	cardHash := make(map[string]int)
+	for _, card := range cards {
+		if !containsString(r.rarity, card.Rarity) {
+			continue
+		}
+		cardHash[card.ID]++
+		if cardHash[card.ID] > r.max {
+			if settings.Verbose {
+				log.Printf("found %d %s, which is more than %d", cardHash[card.ID], card.ID, r.max)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// signpostsRule requires each of a list of cards, by id or name, to show up in the draft.
+type signpostsRule struct {
+	name  string
+	cards []string
+	min   int
+}
+
+func (r *signpostsRule) Name() string { return r.name }
+
+func (r *signpostsRule) CheckDraft(cards []Card, settings *Settings) bool {
+	found := make(map[string]int)
+	for _, card := range cards {
+		found[card.ID]++
+		name := CardName(card)
+		if name != card.ID {
+			found[name]++
+		}
+	}
+	for _, signpost := range r.cards {
+		if found[signpost] < r.min {
+			if settings.Verbose {
+				log.Printf("found %d %s, which is less than %d", found[signpost], signpost, r.min)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// settingsRules returns the rules turned on by flags.
+func settingsRules(settings *Settings) ([]PackRule, []DraftRule) {
+	packRules := []PackRule{&duplicateRule{name: RuleDuplicateInPack}}
+	var draftRules []DraftRule
+
+	if settings.AbortDuplicateThreeColorIdentityUncommons {
+		packRules = append(packRules, &threeColorUncommonsRule{name: RuleDuplicateThreeColorUncommons})
+	}
+	if settings.AbortMissingCommonColor {
+		packRules = append(packRules, &missingColorRule{name: RuleMissingCommonColor})
+	}
+	if settings.AbortMissingCommonColorIdentity {
+		packRules = append(packRules, &missingColorRule{name: RuleMissingCommonColorIdentity, identity: true})
+	}
+	if settings.PackCommonColorStdevMax != 0 {
+		packRules = append(packRules, &colorStdevRule{name: RulePackCommonColorStdevMax, max: settings.PackCommonColorStdevMax})
+	}
+	if settings.PackCommonColorIdentityStdevMax != 0 {
+		packRules = append(packRules, &colorStdevRule{name: RulePackCommonColorIdentityStdevMax, identity: true, max: settings.PackCommonColorIdentityStdevMax})
+	}
+	if settings.PackCommonRatingMin != 0 {
+		packRules = append(packRules, &ratingRule{name: RulePackCommonRatingMin, min: settings.PackCommonRatingMin})
+	}
+	if settings.PackCommonRatingMax != 0 {
+		packRules = append(packRules, &ratingRule{name: RulePackCommonRatingMax, max: settings.PackCommonRatingMax})
+	}
+
+	for _, rarity := range []string{"mythic", "rare", "uncommon", "common"} {
+		if max := maxCopies(rarity, settings); max != 0 {
+			draftRules = append(draftRules, &maxCopiesRule{name: "max-" + rarity, rarity: []string{rarity}, max: max})
+		}
+	}
+	if settings.DraftCommonColorStdevMax != 0 {
+		draftRules = append(draftRules, &colorStdevRule{name: RuleDraftCommonColorStdevMax, max: settings.DraftCommonColorStdevMax})
+	}
+	if settings.DraftCommonColorIdentityStdevMax != 0 {
+		draftRules = append(draftRules, &colorStdevRule{name: RuleDraftCommonColorIdentityStdevMax, identity: true, max: settings.DraftCommonColorIdentityStdevMax})
+	}
+
+	return packRules, draftRules
+}
+
+// MakeRule builds a rule from a set json file. The result is either a PackRule or a DraftRule.
+func MakeRule(def RuleDefinition) (Rule, error) {
+	return makeRule(def, nil)
+}
+
+// makeRule is MakeRule with the set's cards' scryfall info already parsed.
+func makeRule(def RuleDefinition, infos cardInfos) (Rule, error) {
+	name := def.Name
+	if name == "" {
+		name = def.Rule
+	}
+	need := func(fields ...string) error {
+		for _, field := range fields {
+			var missing bool
+			switch field {
+			case "min":
+				missing = def.Min == nil
+			case "max":
+				missing = def.Max == nil
+			case "min or max":
+				missing = def.Min == nil && def.Max == nil
+			case "cards":
+				missing = len(def.Cards) == 0
+			case "rarity":
+				missing = len(def.Rarity) == 0
+			}
+			if missing {
+				return fmt.Errorf("rule %s needs %s", name, field)
+			}
+		}
+		return nil
+	}
+
+	switch def.Rule {
+	case RuleDuplicateInPack:
+		return &duplicateRule{name: name}, nil
+	case RuleUniquePerPack:
+		return &duplicateRule{name: name, all: true}, nil
+	case RuleDuplicateThreeColorUncommons:
+		return &threeColorUncommonsRule{name: name}, nil
+	case RuleMissingCommonColor:
+		return &missingColorRule{name: name}, nil
+	case RuleMissingCommonColorIdentity:
+		return &missingColorRule{name: name, identity: true}, nil
+	case RulePackCommonColorStdevMax, RuleDraftCommonColorStdevMax,
+		RulePackCommonColorIdentityStdevMax, RuleDraftCommonColorIdentityStdevMax:
+		if err := need("max"); err != nil {
+			return nil, err
+		}
+		rule := &colorStdevRule{name: name, identity: strings.Contains(def.Rule, "identity"), max: *def.Max}
+		if strings.HasPrefix(def.Rule, "draft-") {
+			return draftOnly{rule}, nil
+		}
+		return packOnly{rule}, nil
+	case RulePackColorStdevMax, RulePackColorIdentityStdevMax:
+		if err := need("max"); err != nil {
+			return nil, err
+		}
+		return packOnly{&colorStdevRule{name: name, identity: def.Rule == RulePackColorIdentityStdevMax, all: true, max: *def.Max}}, nil
+	case RulePackCommonRatingMin:
+		if err := need("min"); err != nil {
+			return nil, err
+		}
+		return &ratingRule{name: name, min: *def.Min}, nil
+	case RulePackCommonRatingMax:
+		if err := need("max"); err != nil {
+			return nil, err
+		}
+		return &ratingRule{name: name, max: *def.Max}, nil
+	case RuleCreatureCount:
+		if err := need("min or max"); err != nil {
+			return nil, err
+		}
+		return &countRule{name: name, min: def.Min, max: def.Max, rarity: def.Rarity, infos: infos, matches: func(card Card, info ScryfallData) bool {
+			return strings.Contains(info.TypeLine, "Creature")
+		}}, nil
+	case RuleManaCurve:
+		if err := need("min or max"); err != nil {
+			return nil, err
+		}
+		minCmc, maxCmc := def.MinCmc, def.MaxCmc
+		return &countRule{name: name, min: def.Min, max: def.Max, rarity: def.Rarity, infos: infos, matches: func(card Card, info ScryfallData) bool {
+			if strings.Contains(info.TypeLine, "Land") {
+				return false
+			}
+			return (minCmc == nil || info.Cmc >= *minCmc) && (maxCmc == nil || info.Cmc <= *maxCmc)
+		}}, nil
+	case RuleMulticolorCount, RuleColorlessCount, RuleLandCount:
+		if err := need("min or max"); err != nil {
+			return nil, err
+		}
+		group := map[string]string{
+			RuleMulticolorCount: BalanceMulticolor,
+			RuleColorlessCount:  BalanceColorless,
+			RuleLandCount:       BalanceLand,
+		}[def.Rule]
+		return &countRule{name: name, min: def.Min, max: def.Max, rarity: def.Rarity, infos: infos, matches: func(card Card, info ScryfallData) bool {
+			return balanceGroup(card, info, false) == group
+		}}, nil
+	case RuleMaxCopies:
+		if err := need("max", "rarity"); err != nil {
+			return nil, err
+		}
+		return &maxCopiesRule{name: name, rarity: def.Rarity, max: int(*def.Max)}, nil
+	case RuleSignposts:
+		if err := need("cards"); err != nil {
+			return nil, err
+		}
+		min := 1
+		if def.Min != nil {
+			min = int(*def.Min)
+		}
+		return &signpostsRule{name: name, cards: def.Cards, min: min}, nil
+	}
+	return nil, fmt.Errorf("unknown rule %q", def.Rule)
+}
+
+// packOnly and draftOnly hide the other half of rules that can check both.
+type packOnly struct{ PackRule }
+type draftOnly struct{ DraftRule }
+
+// makeRules combines the rules turned on by flags with the ones in the set json file.
+func makeRules(cfg *DraftConfig, infos cardInfos, settings *Settings) ([]PackRule, []DraftRule, error) {
+	packRules, draftRules := settingsRules(settings)
+	for _, def := range cfg.Rules {
+		rule, err := makeRule(def, infos)
+		if err != nil {
+			return nil, nil, err
+		}
+		if packRule, ok := rule.(PackRule); ok {
+			packRules = append(packRules, packRule)
+		}
+		if draftRule, ok := rule.(DraftRule); ok {
+			draftRules = append(draftRules, draftRule)
+		}
+	}
+	return packRules, draftRules, nil
+}
+
+// okPack checks a single pack against the pack rules.
+// It returns the names of the rules the pack fails; none means it passes.
+func okPack(pack [15]Card, rules []PackRule, settings *Settings) []string {
+	var failed []string
+	for _, rule := range rules {
+		if !rule.CheckPack(pack[:], settings) {
+			failed = append(failed, rule.Name())
+		}
+	}
+	if settings.Verbose {
+		if len(failed) == 0 {
+			log.Printf("pack passes!")
+		} else {
+			log.Printf("pack fails :( %v", failed)
+		}
+	}
+	return failed
+}
+
+// okDraft checks the whole draft against the draft rules.
+// It returns the names of the rules the draft fails; none means it passes.
+func okDraft(packs [][15]Card, rules []DraftRule, settings *Settings) []string {
+	if settings.Verbose {
+		log.Printf("analyzing entire draft pool...")
+	}
+	var cards []Card
+	for _, pack := range packs {
+		cards = append(cards, pack[:]...)
+	}
+	var failed []string
+	for _, rule := range rules {
+		if !rule.CheckDraft(cards, settings) {
+			failed = append(failed, rule.Name())
+		}
+	}
+	if settings.Verbose {
+		if len(failed) == 0 {
+			log.Printf("draft passes!")
+		} else {
+			log.Printf("draft fails :( %v", failed)
+		}
+	}
+	return failed
+}
+
+// countedCommons returns the commons that count towards color and rating stats:
+// everything but foils and, in dfc mode, DFCs.
+func countedCommons(cards []Card, settings *Settings) []Card {
+	var ret []Card
+	for _, card := range cards {
+		if card.Rarity == "common" && !(card.Foil || (settings.DfcMode && card.Dfc)) {
+			ret = append(ret, card)
+		}
+	}
+	return ret
+}
+
+// commonColorCounts counts each color among the counted commons.
+// Colors that don't show up at all are left out.
+func commonColorCounts(cards []Card, identity bool, settings *Settings) []float64 {
+	colorHash := make(map[rune]float64)
+	for _, card := range countedCommons(cards, settings) {
+		colors := card.Color
+		if identity {
+			colors = card.ColorIdentity
+		}
+		for _, color := range colors {
+			colorHash[color]++
+		}
+	}
+	var ret []float64
+	for _, v := range colorHash {
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+// allColorCounts counts each of the five colors among every card but basics,
+// including the colors that don't show up at all.
+func allColorCounts(cards []Card, identity bool) []float64 {
+	colorHash := make(map[rune]float64)
+	for _, card := range cards {
+		if card.Rarity == "basic" {
+			continue
+		}
+		colors := card.Color
+		if identity {
+			colors = card.ColorIdentity
+		}
+		for _, color := range colors {
+			colorHash[color]++
+		}
+	}
+	var ret []float64
+	for _, color := range "WUBRG" {
+		ret = append(ret, colorHash[color])
+	}
+	return ret
+}
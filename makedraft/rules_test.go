@@ -0,0 +1,212 @@
+package makedraft
+
+import (
+	"strings"
+	"testing"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func foil(card Card) Card {
+	card.Foil = true
+	return card
+}
+
+func dfc(card Card) Card {
+	card.Dfc = true
+	return card
+}
+
+func rated(card Card, rating float64) Card {
+	card.Rating = rating
+	return card
+}
+
+func TestMakeRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		def       RuleDefinition
+		wantName  string
+		wantPack  bool
+		wantDraft bool
+		wantErr   string
+	}{
+		{"default name", RuleDefinition{Rule: RuleDuplicateInPack}, RuleDuplicateInPack, true, false, ""},
+		{"named duplicate", RuleDefinition{Rule: RuleUniquePerPack, Name: "singleton"}, "singleton", true, false, ""},
+		{"named three color uncommons", RuleDefinition{Rule: RuleDuplicateThreeColorUncommons, Name: "gold"}, "gold", true, false, ""},
+		{"named missing color", RuleDefinition{Rule: RuleMissingCommonColor, Name: "colors"}, "colors", true, false, ""},
+		{"named missing color identity", RuleDefinition{Rule: RuleMissingCommonColorIdentity, Name: "identities"}, "identities", true, false, ""},
+		{"pack stdev is pack only", RuleDefinition{Rule: RulePackCommonColorStdevMax, Max: float(1)}, RulePackCommonColorStdevMax, true, false, ""},
+		{"draft stdev is draft only", RuleDefinition{Rule: RuleDraftCommonColorIdentityStdevMax, Max: float(1)}, RuleDraftCommonColorIdentityStdevMax, false, true, ""},
+		{"all colors stdev", RuleDefinition{Rule: RulePackColorStdevMax, Name: "even", Max: float(1)}, "even", true, false, ""},
+		{"rating", RuleDefinition{Rule: RulePackCommonRatingMin, Min: float(2)}, RulePackCommonRatingMin, true, false, ""},
+		{"named creature count", RuleDefinition{Rule: RuleCreatureCount, Name: "creatures", Min: float(3)}, "creatures", true, false, ""},
+		{"mana curve", RuleDefinition{Rule: RuleManaCurve, Max: float(1), MinCmc: float(5)}, RuleManaCurve, true, false, ""},
+		{"land count", RuleDefinition{Rule: RuleLandCount, Max: float(1)}, RuleLandCount, true, false, ""},
+		{"max copies", RuleDefinition{Rule: RuleMaxCopies, Max: float(1), Rarity: []string{"rare"}}, RuleMaxCopies, false, true, ""},
+		{"signposts", RuleDefinition{Rule: RuleSignposts, Cards: []string{"a"}}, RuleSignposts, false, true, ""},
+		{"stdev without max", RuleDefinition{Rule: RulePackColorStdevMax}, "", false, false, "needs max"},
+		{"rating without min", RuleDefinition{Rule: RulePackCommonRatingMin, Name: "good"}, "", false, false, "rule good needs min"},
+		{"count without min or max", RuleDefinition{Rule: RuleCreatureCount}, "", false, false, "needs min or max"},
+		{"max copies without rarity", RuleDefinition{Rule: RuleMaxCopies, Max: float(1)}, "", false, false, "needs rarity"},
+		{"signposts without cards", RuleDefinition{Rule: RuleSignposts}, "", false, false, "needs cards"},
+		{"unknown rule", RuleDefinition{Rule: "no-such-rule"}, "", false, false, `unknown rule "no-such-rule"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := MakeRule(test.def)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("MakeRule(%+v) error = %v, want %q", test.def, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MakeRule(%+v) error = %v", test.def, err)
+			}
+			if rule.Name() != test.wantName {
+				t.Errorf("MakeRule(%+v).Name() = %q, want %q", test.def, rule.Name(), test.wantName)
+			}
+			_, isPack := rule.(PackRule)
+			_, isDraft := rule.(DraftRule)
+			if isPack != test.wantPack || isDraft != test.wantDraft {
+				t.Errorf("MakeRule(%+v) is a pack rule %v and a draft rule %v, want %v and %v",
+					test.def, isPack, isDraft, test.wantPack, test.wantDraft)
+			}
+		})
+	}
+}
+
+func TestPackRules(t *testing.T) {
+	a := testCard("a", "common", "W", "Creature", 2)
+	b := testCard("b", "common", "U", "Creature", 2)
+	plains := testCard("plains", "basic", "", "Basic Land — Plains", 0)
+	w := testCard("w", "common", "W", "Instant", 1)
+	u := testCard("u", "common", "U", "Instant", 1)
+	k := testCard("k", "common", "B", "Instant", 1)
+	r := testCard("r", "common", "R", "Instant", 1)
+	g := testCard("g", "common", "G", "Instant", 1)
+	gold := testCard("gold", "uncommon", "BUW", "Creature", 3)
+	gold2 := testCard("gold2", "uncommon", "WUB", "Creature", 3)
+	whiteUncommon := testCard("wu1", "uncommon", "W", "Creature", 3)
+	dragon := testCard("dragon", "rare", "R", "Creature — Dragon", 6)
+	bomb := testCard("bomb", "rare", "B", "Sorcery", 7)
+	bigLand := testCard("bigland", "rare", "", "Land", 8)
+	relic := testCard("relic", "uncommon", "", "Artifact", 3)
+	guildmage := testCard("guildmage", "uncommon", "WU", "Creature", 2)
+	dual := testCard("dual", "common", "", "Land", 0)
+
+	tests := []struct {
+		name     string
+		def      RuleDefinition
+		settings Settings
+		pack     []Card
+		want     bool
+	}{
+		{"no duplicates", RuleDefinition{Rule: RuleDuplicateInPack}, Settings{}, []Card{a, b}, true},
+		{"duplicate", RuleDefinition{Rule: RuleDuplicateInPack}, Settings{}, []Card{a, b, a}, false},
+		{"foil duplicate is fine", RuleDefinition{Rule: RuleDuplicateInPack}, Settings{}, []Card{a, foil(a)}, true},
+		{"dfc duplicate is fine in dfc mode", RuleDefinition{Rule: RuleDuplicateInPack}, Settings{DfcMode: true}, []Card{dfc(a), dfc(a)}, true},
+		{"dfc duplicate outside dfc mode", RuleDefinition{Rule: RuleDuplicateInPack}, Settings{}, []Card{dfc(a), dfc(a)}, false},
+		{"unique counts foils", RuleDefinition{Rule: RuleUniquePerPack}, Settings{}, []Card{a, foil(a)}, false},
+		{"unique ignores basics", RuleDefinition{Rule: RuleUniquePerPack}, Settings{}, []Card{plains, plains, a}, true},
+
+		{"one three color uncommon", RuleDefinition{Rule: RuleDuplicateThreeColorUncommons}, Settings{}, []Card{gold, whiteUncommon}, true},
+		{"two of a three color identity", RuleDefinition{Rule: RuleDuplicateThreeColorUncommons}, Settings{}, []Card{gold, gold2}, false},
+		{"two mono color uncommons", RuleDefinition{Rule: RuleDuplicateThreeColorUncommons}, Settings{}, []Card{whiteUncommon, whiteUncommon}, true},
+		{"three mono color uncommons", RuleDefinition{Rule: RuleDuplicateThreeColorUncommons}, Settings{}, []Card{whiteUncommon, whiteUncommon, whiteUncommon}, false},
+
+		{"every color among commons", RuleDefinition{Rule: RuleMissingCommonColor}, Settings{}, []Card{w, u, k, r, g}, true},
+		{"color missing among commons", RuleDefinition{Rule: RuleMissingCommonColor}, Settings{}, []Card{w, u, k, r, dragon}, false},
+		{"foils don't count for colors", RuleDefinition{Rule: RuleMissingCommonColor}, Settings{}, []Card{w, u, k, r, foil(g)}, false},
+		{"every color identity", RuleDefinition{Rule: RuleMissingCommonColorIdentity}, Settings{}, []Card{w, u, k, r, g}, true},
+
+		{"even commons", RuleDefinition{Rule: RulePackCommonColorStdevMax, Max: float(0.5)}, Settings{}, []Card{w, w, u, k, r, g}, true},
+		{"uneven commons", RuleDefinition{Rule: RulePackCommonColorStdevMax, Max: float(0.5)}, Settings{}, []Card{w, w, w, u}, false},
+		{"missing colors don't count", RuleDefinition{Rule: RulePackCommonColorStdevMax, Max: float(0.4)}, Settings{}, []Card{w, u}, true},
+		{"missing colors count with abort-missing", RuleDefinition{Rule: RulePackCommonColorStdevMax, Max: float(0.4)},
+			Settings{AbortMissingCommonColor: true}, []Card{w, u}, false},
+		{"every card's colors", RuleDefinition{Rule: RulePackColorStdevMax, Max: float(0.5)}, Settings{}, []Card{w, u, k, dragon, g}, true},
+		{"every card's colors, uneven", RuleDefinition{Rule: RulePackColorStdevMax, Max: float(0.5)}, Settings{}, []Card{w, w, whiteUncommon}, false},
+
+		{"rating high enough", RuleDefinition{Rule: RulePackCommonRatingMin, Min: float(3)}, Settings{}, []Card{rated(w, 4), rated(u, 3)}, true},
+		{"rating too low", RuleDefinition{Rule: RulePackCommonRatingMin, Min: float(3)}, Settings{}, []Card{rated(w, 4), rated(u, 1)}, false},
+		{"rating only counts commons", RuleDefinition{Rule: RulePackCommonRatingMax, Max: float(3)}, Settings{}, []Card{rated(w, 2), rated(dragon, 5)}, true},
+
+		{"enough creatures", RuleDefinition{Rule: RuleCreatureCount, Min: float(2)}, Settings{}, []Card{a, dragon, w}, true},
+		{"too few creatures", RuleDefinition{Rule: RuleCreatureCount, Min: float(2)}, Settings{}, []Card{a, w}, false},
+		{"creatures of a rarity", RuleDefinition{Rule: RuleCreatureCount, Max: float(1), Rarity: []string{"common"}}, Settings{}, []Card{a, dragon, gold}, true},
+		{"too many creatures", RuleDefinition{Rule: RuleCreatureCount, Max: float(1)}, Settings{}, []Card{a, b}, false},
+
+		{"curve is fine", RuleDefinition{Rule: RuleManaCurve, MinCmc: float(6), Max: float(1)}, Settings{}, []Card{dragon, a}, true},
+		{"too top heavy", RuleDefinition{Rule: RuleManaCurve, MinCmc: float(6), Max: float(1)}, Settings{}, []Card{dragon, bomb}, false},
+		{"lands aren't on the curve", RuleDefinition{Rule: RuleManaCurve, MinCmc: float(6), Max: float(1)}, Settings{}, []Card{dragon, bigLand}, true},
+		{"cmc range", RuleDefinition{Rule: RuleManaCurve, MinCmc: float(2), MaxCmc: float(3), Min: float(3)}, Settings{}, []Card{a, b, gold, w}, true},
+
+		{"no multicolor", RuleDefinition{Rule: RuleMulticolorCount, Max: float(0)}, Settings{}, []Card{a, b}, true},
+		{"multicolor", RuleDefinition{Rule: RuleMulticolorCount, Max: float(0)}, Settings{}, []Card{a, guildmage}, false},
+		{"colorless", RuleDefinition{Rule: RuleColorlessCount, Min: float(1)}, Settings{}, []Card{a, relic}, true},
+		{"lands aren't colorless", RuleDefinition{Rule: RuleColorlessCount, Min: float(1)}, Settings{}, []Card{a, dual}, false},
+		{"too many lands", RuleDefinition{Rule: RuleLandCount, Max: float(0)}, Settings{}, []Card{a, dual}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infos := makeCardInfos(test.pack)
+			for _, parsed := range []cardInfos{nil, infos} {
+				rule, err := makeRule(test.def, parsed)
+				if err != nil {
+					t.Fatalf("makeRule(%+v) error = %v", test.def, err)
+				}
+				packRule, ok := rule.(PackRule)
+				if !ok {
+					t.Fatalf("makeRule(%+v) isn't a pack rule", test.def)
+				}
+				settings := test.settings
+				if got := packRule.CheckPack(test.pack, &settings); got != test.want {
+					t.Errorf("%s.CheckPack() = %v, want %v (parsed ahead: %v)", rule.Name(), got, test.want, parsed != nil)
+				}
+			}
+		})
+	}
+}
+
+func TestDraftRules(t *testing.T) {
+	w := testCard("w", "common", "W", "Instant", 1)
+	u := testCard("u", "common", "U", "Instant", 1)
+	dragon := testCard("dragon", "rare", "R", "Creature — Dragon", 6)
+	signpost := testCard("s1", "uncommon", "WU", "Creature", 2)
+
+	tests := []struct {
+		name  string
+		def   RuleDefinition
+		cards []Card
+		want  bool
+	}{
+		{"one copy", RuleDefinition{Rule: RuleMaxCopies, Max: float(1), Rarity: []string{"rare"}}, []Card{dragon, w, w}, true},
+		{"too many copies", RuleDefinition{Rule: RuleMaxCopies, Max: float(1), Rarity: []string{"rare"}}, []Card{dragon, dragon}, false},
+		{"copies of other rarities", RuleDefinition{Rule: RuleMaxCopies, Max: float(1), Rarity: []string{"rare"}}, []Card{w, w, w}, true},
+		{"signpost by id", RuleDefinition{Rule: RuleSignposts, Cards: []string{"s1"}}, []Card{signpost}, true},
+		{"signpost by name", RuleDefinition{Rule: RuleSignposts, Cards: []string{"dragon"}}, []Card{dragon}, true},
+		{"missing signpost", RuleDefinition{Rule: RuleSignposts, Cards: []string{"s1", "dragon"}}, []Card{signpost}, false},
+		{"too few signposts", RuleDefinition{Rule: RuleSignposts, Cards: []string{"s1"}, Min: float(2)}, []Card{signpost}, false},
+		{"even draft", RuleDefinition{Rule: RuleDraftCommonColorStdevMax, Max: float(0.5)}, []Card{w, u, w, u}, true},
+		{"uneven draft", RuleDefinition{Rule: RuleDraftCommonColorStdevMax, Max: float(0.5)}, []Card{w, w, w, u}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := MakeRule(test.def)
+			if err != nil {
+				t.Fatalf("MakeRule(%+v) error = %v", test.def, err)
+			}
+			draftRule, ok := rule.(DraftRule)
+			if !ok {
+				t.Fatalf("MakeRule(%+v) isn't a draft rule", test.def)
+			}
+			if got := draftRule.CheckDraft(test.cards, &Settings{}); got != test.want {
+				t.Errorf("%s.CheckDraft() = %v, want %v", rule.Name(), got, test.want)
+			}
+		})
+	}
+}
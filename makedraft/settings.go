@@ -0,0 +1,121 @@
+package makedraft
+
+import (
+	"flag"
+	"io/ioutil"
+	"time"
+)
+
+// Settings stores all the settings that control how a draft is generated.
+type Settings struct {
+	Name                                      string
+	Seed                                      int64
+	Verbose                                   bool
+	MaxMythic                                 int
+	MaxRare                                   int
+	MaxUncommon                               int
+	MaxCommon                                 int
+	PackCommonColorStdevMax                   float64
+	PackCommonRatingMin                       float64
+	PackCommonRatingMax                       float64
+	DraftCommonColorStdevMax                  float64
+	PackCommonColorIdentityStdevMax           float64
+	DraftCommonColorIdentityStdevMax          float64
+	DfcMode                                   bool
+	AbortMissingCommonColor                   bool
+	AbortMissingCommonColorIdentity           bool
+	AbortDuplicateThreeColorIdentityUncommons bool
+	MaxDraftAttempts                          int
+	MaxPackAttempts                           int
+	Timeout                                   time.Duration
+	// Deadline, if set, is when to give up no matter how long Timeout is. It isn't a
+	// flag: callers set it so several sets in a mixed draft share one time budget.
+	Deadline time.Time
+}
+
+// DefaultSettings returns the settings used when no flags are given.
+func DefaultSettings() Settings {
+	return Settings{
+		Name:        "untitled draft",
+		MaxMythic:   2,
+		MaxRare:     3,
+		MaxUncommon: 4,
+		MaxCommon:   6,
+
+		MaxDraftAttempts: 10000,
+		MaxPackAttempts:  1000000,
+		Timeout:          2 * time.Minute,
+	}
+}
+
+// RegisterFlags binds every setting to a flag in flagSet, using the current values as defaults.
+func (s *Settings) RegisterFlags(flagSet *flag.FlagSet) {
+	flagSet.Int64Var(&s.Seed,
+		"seed", s.Seed,
+		"The random seed to use to generate the draft. If 0, time.Now().UnixNano() will be used.")
+	flagSet.BoolVar(&s.Verbose,
+		"v", s.Verbose,
+		"If true, will enable verbose output.")
+	flagSet.StringVar(&s.Name,
+		"name", s.Name,
+		"The name of the draft.")
+	flagSet.IntVar(&s.MaxMythic,
+		"max-mythic", s.MaxMythic,
+		"Maximum number of copies of a given mythic allowed in a draft. 0 to disable.")
+	flagSet.IntVar(&s.MaxRare,
+		"max-rare", s.MaxRare,
+		"Maximum number of copies of a given rare allowed in a draft. 0 to disable.")
+	flagSet.IntVar(&s.MaxUncommon,
+		"max-uncommon", s.MaxUncommon,
+		"Maximum number of copies of a given uncommon allowed in a draft. 0 to disable.")
+	flagSet.IntVar(&s.MaxCommon,
+		"max-common", s.MaxCommon,
+		"Maximum number of copies of a given common allowed in a draft. 0 to disable.")
+	flagSet.Float64Var(&s.PackCommonColorStdevMax,
+		"pack-common-color-stdev-max", s.PackCommonColorStdevMax,
+		"Maximum standard deviation allowed in a pack of color distribution among commons. 0 to disable.")
+	flagSet.Float64Var(&s.PackCommonRatingMin,
+		"pack-common-rating-min", s.PackCommonRatingMin,
+		"Minimum average rating allowed in a pack among commons. 0 to disable.")
+	flagSet.Float64Var(&s.PackCommonRatingMax,
+		"pack-common-rating-max", s.PackCommonRatingMax,
+		"Maximum average rating allowed in a pack among commons. 0 to disable.")
+	flagSet.Float64Var(&s.DraftCommonColorStdevMax,
+		"draft-common-color-stdev-max", s.DraftCommonColorStdevMax,
+		"Maximum standard deviation allowed in the entire draft of color distribution among commons. 0 to disable.")
+	flagSet.Float64Var(&s.PackCommonColorIdentityStdevMax,
+		"pack-common-color-identity-stdev-max", s.PackCommonColorIdentityStdevMax,
+		"Maximum standard deviation allowed in a pack of color identity distribution among commons. 0 to disable.")
+	flagSet.Float64Var(&s.DraftCommonColorIdentityStdevMax,
+		"draft-common-color-identity-stdev-max", s.DraftCommonColorIdentityStdevMax,
+		"Maximum standard deviation allowed in the entire draft of color identity distribution among commons. 0 to disable.")
+	flagSet.BoolVar(&s.DfcMode,
+		"dfc-mode", s.DfcMode,
+		"If true, include DFCs only in DFC specific hoppers and exclude them from color distribution stats.")
+	flagSet.BoolVar(&s.AbortMissingCommonColor,
+		"abort-missing-common-color", s.AbortMissingCommonColor,
+		"If true, every color will be represented in the colors of commons in every pack.")
+	flagSet.BoolVar(&s.AbortMissingCommonColorIdentity,
+		"abort-missing-common-color-identity", s.AbortMissingCommonColorIdentity,
+		"If true, every color will be represented in the color identities of commons in every pack.")
+	flagSet.BoolVar(&s.AbortDuplicateThreeColorIdentityUncommons,
+		"abort-duplicate-three-color-identity-uncommons", s.AbortDuplicateThreeColorIdentityUncommons,
+		"If true, only one uncommon of a color identity triplet will be allowed per pack.")
+	flagSet.IntVar(&s.MaxDraftAttempts,
+		"max-draft-attempts", s.MaxDraftAttempts,
+		"Give up after generating this many drafts without finding one that passes. 0 to disable.")
+	flagSet.IntVar(&s.MaxPackAttempts,
+		"max-pack-attempts", s.MaxPackAttempts,
+		"Give up after generating this many packs in total without finding a draft that passes. 0 to disable.")
+	flagSet.DurationVar(&s.Timeout,
+		"timeout", s.Timeout,
+		"Give up if no draft passes within this long. 0 to disable.")
+}
+
+// ApplyFlags parses command line style arguments, like the ones stored in set json files, over the settings.
+func (s *Settings) ApplyFlags(args []string) error {
+	flagSet := flag.NewFlagSet("makedraft", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
+	s.RegisterFlags(flagSet)
+	return flagSet.Parse(args)
+}
@@ -0,0 +1,219 @@
+package makedraft
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// Simulation summarizes many drafts generated in memory from the same set and settings.
+type Simulation struct {
+	Seed          int64 `json:"seed"`
+	Drafts        int   `json:"drafts"`
+	Failures      int   `json:"failures"`
+	DraftAttempts int   `json:"draftAttempts"`
+	PackAttempts  int   `json:"packAttempts"`
+	// PackRejections and DraftRejections count rejections per constraint, including
+	// drafts that failed, with their rate per pack or draft attempt.
+	PackRejections  []SimulatedRejection `json:"packRejections"`
+	DraftRejections []SimulatedRejection `json:"draftRejections"`
+	Slots           []SlotStats          `json:"slots"`
+	Cards           []CardStats          `json:"cards"`
+	// The spread of color balance among commons, per draft and per pack.
+	DraftCommonColorStdev         Spread `json:"draftCommonColorStdev"`
+	DraftCommonColorIdentityStdev Spread `json:"draftCommonColorIdentityStdev"`
+	PackCommonColorStdev          Spread `json:"packCommonColorStdev"`
+	PackCommonColorIdentityStdev  Spread `json:"packCommonColorIdentityStdev"`
+}
+
+// SimulatedRejection is how often a constraint rejected packs or drafts.
+type SimulatedRejection struct {
+	Constraint string  `json:"constraint"`
+	Count      int     `json:"count"`
+	Rate       float64 `json:"rate"`
+}
+
+// SlotStats describes what a slot in the pack ended up holding, as a share of all packs.
+type SlotStats struct {
+	Slot     int                `json:"slot"`
+	Rarities map[string]float64 `json:"rarities"`
+	Foil     float64            `json:"foil"`
+}
+
+// CardStats describes how often a card showed up.
+type CardStats struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Rarity string `json:"rarity"`
+	// DraftRate is the share of drafts the card showed up in at all.
+	DraftRate float64 `json:"draftRate"`
+	// CopiesPerDraft and FoilsPerDraft are average copies per draft.
+	CopiesPerDraft float64 `json:"copiesPerDraft"`
+	FoilsPerDraft  float64 `json:"foilsPerDraft"`
+}
+
+// Spread summarizes a list of values.
+type Spread struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P10  float64 `json:"p10"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	Max  float64 `json:"max"`
+}
+
+// Simulate generates n drafts in memory and collects statistics about them.
+// Draft i uses seed settings.Seed+i, so any of them can be reproduced with makedraft.
+// Drafts that can't be generated within the limits in settings count as failures.
+func Simulate(cfg DraftConfig, settings Settings, n int) (Simulation, error) {
+	result := Simulation{Seed: settings.Seed}
+	if result.Seed == 0 {
+		result.Seed = time.Now().UnixNano()
+	}
+
+	packRejections := make(Rejections)
+	draftRejections := make(Rejections)
+	var slotRarities [15]map[string]int
+	var slotFoils [15]int
+	for i := range slotRarities {
+		slotRarities[i] = make(map[string]int)
+	}
+	copies := make(map[string]int)
+	foils := make(map[string]int)
+	drafts := make(map[string]int)
+	var draftColor, draftColorIdentity, packColor, packColorIdentity []float64
+
+	for i := 0; i < n; i++ {
+		settings.Seed = result.Seed + int64(i)
+		draft, err := GenerateDraft(cfg, settings)
+		var genErr *GenerationError
+		if errors.As(err, &genErr) {
+			result.Failures++
+			result.DraftAttempts += genErr.DraftAttempts
+			result.PackAttempts += genErr.PackAttempts
+			addRejections(packRejections, genErr.PackRejections)
+			addRejections(draftRejections, genErr.DraftRejections)
+			continue
+		} else if err != nil {
+			return result, err
+		}
+
+		result.Drafts++
+		result.DraftAttempts += draft.DraftAttempts
+		result.PackAttempts += draft.PackAttempts
+		addRejections(packRejections, draft.PackRejections)
+		addRejections(draftRejections, draft.DraftRejections)
+
+		var all []Card
+		seen := make(map[string]bool)
+		for _, pack := range draft.Packs {
+			for j, card := range pack {
+				slotRarities[j][card.Rarity]++
+				if card.Foil {
+					slotFoils[j]++
+					foils[card.ID]++
+				}
+				copies[card.ID]++
+				if !seen[card.ID] {
+					seen[card.ID] = true
+					drafts[card.ID]++
+				}
+				all = append(all, card)
+			}
+			stats := distribution(pack[:], &settings)
+			packColor = append(packColor, stats.CommonColorStdev)
+			packColorIdentity = append(packColorIdentity, stats.CommonColorIdentityStdev)
+		}
+		stats := distribution(all, &settings)
+		draftColor = append(draftColor, stats.CommonColorStdev)
+		draftColorIdentity = append(draftColorIdentity, stats.CommonColorIdentityStdev)
+	}
+
+	result.PackRejections = rejectionRates(packRejections, result.PackAttempts)
+	result.DraftRejections = rejectionRates(draftRejections, result.DraftAttempts)
+
+	packs := float64(result.Drafts * 24)
+	for j := range slotRarities {
+		slot := SlotStats{Slot: j, Rarities: make(map[string]float64)}
+		if packs > 0 {
+			for rarity, count := range slotRarities[j] {
+				slot.Rarities[rarity] = float64(count) / packs
+			}
+			slot.Foil = float64(slotFoils[j]) / packs
+		}
+		result.Slots = append(result.Slots, slot)
+	}
+
+	// every card in the set is listed, so cards that never showed up stand out.
+	listed := make(map[string]bool)
+	for _, card := range cfg.Cards {
+		if listed[card.ID] {
+			continue
+		}
+		listed[card.ID] = true
+		stats := CardStats{ID: card.ID, Name: CardName(card), Rarity: card.Rarity}
+		if result.Drafts > 0 {
+			stats.DraftRate = float64(drafts[card.ID]) / float64(result.Drafts)
+			stats.CopiesPerDraft = float64(copies[card.ID]) / float64(result.Drafts)
+			stats.FoilsPerDraft = float64(foils[card.ID]) / float64(result.Drafts)
+		}
+		result.Cards = append(result.Cards, stats)
+	}
+	sort.SliceStable(result.Cards, func(a, b int) bool {
+		if rarityOrder[result.Cards[a].Rarity] != rarityOrder[result.Cards[b].Rarity] {
+			return rarityOrder[result.Cards[a].Rarity] < rarityOrder[result.Cards[b].Rarity]
+		}
+		return result.Cards[a].CopiesPerDraft > result.Cards[b].CopiesPerDraft
+	})
+
+	result.DraftCommonColorStdev = spread(draftColor)
+	result.DraftCommonColorIdentityStdev = spread(draftColorIdentity)
+	result.PackCommonColorStdev = spread(packColor)
+	result.PackCommonColorIdentityStdev = spread(packColorIdentity)
+
+	return result, nil
+}
+
+func addRejections(total Rejections, more Rejections) {
+	for constraint, count := range more {
+		total[constraint] += count
+	}
+}
+
+func rejectionRates(rejections Rejections, attempts int) []SimulatedRejection {
+	ret := []SimulatedRejection{}
+	for _, rejection := range rejections.Sorted() {
+		ret = append(ret, SimulatedRejection{
+			Constraint: rejection.Constraint,
+			Count:      rejection.Count,
+			Rate:       float64(rejection.Count) / float64(attempts),
+		})
+	}
+	return ret
+}
+
+// spread summarizes values, ignoring the NaNs that come from packs without commons.
+func spread(values []float64) Spread {
+	var sorted []float64
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return Spread{}
+	}
+	sort.Float64s(sorted)
+	percentile := func(p float64) float64 {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	return Spread{
+		Min:  sorted[0],
+		Mean: mean(sorted),
+		P10:  percentile(0.1),
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		Max:  sorted[len(sorted)-1],
+	}
+}
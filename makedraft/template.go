@@ -0,0 +1,332 @@
+package makedraft
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// PoolDefinition is part of DraftConfig and describes a shared stack of cards
+// that slots draw from. Slots that name the same pool share its cards, the
+// same way Pointer hoppers used to.
+type PoolDefinition struct {
+	Name    string       `json:"name"`
+	Type    string       `json:"type,omitempty"`
+	Sheet   string       `json:"sheet,omitempty"`
+	Sources []PoolSource `json:"sources,omitempty"`
+	Refill  bool         `json:"refill,omitempty"`
+	Foil    bool         `json:"foil,omitempty"`
+	// Balance and BalanceBy are for balanced pools. Balance is how many cards of each
+	// group every pack gets; without it packs get each group's share of the pool.
+	Balance   map[string]int `json:"balance,omitempty"`
+	BalanceBy string         `json:"balance_by,omitempty"`
+}
+
+// SheetDefinition is part of DraftConfig and lists the card ids on a print sheet in
+// the order they're printed. Pools of type sheet cut runs from it.
+type SheetDefinition struct {
+	Name  string   `json:"name"`
+	Cards []string `json:"cards"`
+}
+
+// PoolSource selects cards for a pool. Every field that is set must match,
+// and each matching card is added Copies times.
+type PoolSource struct {
+	Rarity        []string `json:"rarity,omitempty"`
+	Dfc           *bool    `json:"dfc,omitempty"`
+	Color         []string `json:"color,omitempty"`
+	ColorIdentity []string `json:"color_identity,omitempty"`
+	MinRating     *float64 `json:"min_rating,omitempty"`
+	MaxRating     *float64 `json:"max_rating,omitempty"`
+	IDs           []string `json:"ids,omitempty"`
+	Copies        int      `json:"copies,omitempty"`
+}
+
+// SlotDefinition is part of DraftConfig and describes one of the 15 slots in a pack.
+// A slot either always draws from Pool, or picks one of Options with probability
+// proportional to its weight.
+type SlotDefinition struct {
+	Pool    string       `json:"pool,omitempty"`
+	Options []SlotOption `json:"options,omitempty"`
+}
+
+// SlotOption is one of the pools a slot may draw from.
+type SlotOption struct {
+	Pool   string  `json:"pool"`
+	Weight float64 `json:"weight"`
+}
+
+// Pool types.
+const (
+	PoolTypeNormal = "normal"
+	PoolTypeBasic  = "basic"
+	PoolTypeSheet  = "sheet"
+	// Balanced pools plan the colors of every pack, for cubes.
+	PoolTypeBalanced = "balanced"
+)
+
+// WeightedHopper draws from one of several hoppers, chosen at random by weight.
+type WeightedHopper struct {
+	Hoppers []Hopper
+	Weights []float64
+	total   float64
+	rng     *rand.Rand
+}
+
+// Pop returns a card from one of the hoppers and reports if that hopper is now empty.
+func (h *WeightedHopper) Pop() (Card, bool) {
+	r := h.rng.Float64() * h.total
+	for i, weight := range h.Weights {
+		if r < weight {
+			return h.Hoppers[i].Pop()
+		}
+		r -= weight
+	}
+	return h.Hoppers[len(h.Hoppers)-1].Pop()
+}
+
+// Refill refills every hopper this one draws from.
+func (h *WeightedHopper) Refill() {
+	for _, hopper := range h.Hoppers {
+		hopper.Refill()
+	}
+}
+
+// StartPack passes the start of a pack on to every hopper this one draws from.
+func (h *WeightedHopper) StartPack() {
+	for _, hopper := range h.Hoppers {
+		if starter, ok := hopper.(PackStarter); ok {
+			starter.StartPack()
+		}
+	}
+}
+
+// MakeWeightedHopper creates a WeightedHopper.
+func MakeWeightedHopper(rng *rand.Rand, hoppers []Hopper, weights []float64) *WeightedHopper {
+	ret := WeightedHopper{Hoppers: hoppers, Weights: weights, rng: rng}
+	for _, weight := range weights {
+		ret.total += weight
+	}
+	return &ret
+}
+
+// Matches reports whether a card belongs in a pool source.
+func (s *PoolSource) Matches(card Card) bool {
+	if len(s.Rarity) > 0 && !containsString(s.Rarity, card.Rarity) {
+		return false
+	}
+	if s.Dfc != nil && *s.Dfc != card.Dfc {
+		return false
+	}
+	if len(s.Color) > 0 && !containsString(s.Color, stringSort(card.Color)) {
+		return false
+	}
+	if len(s.ColorIdentity) > 0 && !containsString(s.ColorIdentity, stringSort(card.ColorIdentity)) {
+		return false
+	}
+	if s.MinRating != nil && card.Rating < *s.MinRating {
+		return false
+	}
+	if s.MaxRating != nil && card.Rating > *s.MaxRating {
+		return false
+	}
+	if len(s.IDs) > 0 && !containsString(s.IDs, card.ID) {
+		return false
+	}
+	return true
+}
+
+// poolCards collects the cards for a pool from every source.
+func poolCards(pool *PoolDefinition, cards []Card) []Card {
+	var ret []Card
+	for _, source := range pool.Sources {
+		copies := source.Copies
+		if copies == 0 {
+			copies = 1
+		}
+		for _, card := range cards {
+			if !source.Matches(card) {
+				continue
+			}
+			for i := 0; i < copies; i++ {
+				ret = append(ret, card)
+			}
+		}
+	}
+	return ret
+}
+
+// makeSlotHoppers builds fresh hoppers for every slot from the pools in cfg.
+func makeSlotHoppers(cfg *DraftConfig, infos cardInfos, rng *rand.Rand) ([15]Hopper, error) {
+	var hoppers [15]Hopper
+
+	if len(cfg.Slots) != len(hoppers) {
+		return hoppers, fmt.Errorf("packs need exactly %d slots, not %d", len(hoppers), len(cfg.Slots))
+	}
+
+	sheets, err := sheetCards(cfg)
+	if err != nil {
+		return hoppers, err
+	}
+
+	perPack := make(map[string]int)
+	for _, slot := range cfg.Slots {
+		if slot.Pool != "" {
+			perPack[slot.Pool]++
+		}
+	}
+
+	pools := make(map[string]Hopper)
+	for i := range cfg.Pools {
+		pool := &cfg.Pools[i]
+		if _, ok := pools[pool.Name]; ok {
+			return hoppers, fmt.Errorf("pool %q is defined more than once", pool.Name)
+		}
+		var cards []Card
+		if pool.Type == PoolTypeSheet {
+			sheet, ok := sheets[pool.Sheet]
+			if !ok {
+				return hoppers, fmt.Errorf("pool %q uses unknown sheet %q", pool.Name, pool.Sheet)
+			}
+			cards = append(cards, sheet...)
+		} else {
+			cards = poolCards(pool, cfg.Cards)
+		}
+		if len(cards) == 0 {
+			return hoppers, fmt.Errorf("pool %q has no cards", pool.Name)
+		}
+		if pool.Foil {
+			for j := range cards {
+				cards[j].Foil = true
+			}
+		}
+		switch pool.Type {
+		case "", PoolTypeNormal:
+			pools[pool.Name] = MakeNormalHopper(rng, pool.Refill, cards)
+		case PoolTypeBasic:
+			pools[pool.Name] = MakeBasicLandHopper(cards)
+		case PoolTypeSheet:
+			pools[pool.Name] = MakeSheetHopper(rng, cards)
+		case PoolTypeBalanced:
+			hopper, err := makeBalancedHopper(pool, cards, perPack[pool.Name], infos, rng)
+			if err != nil {
+				return hoppers, err
+			}
+			pools[pool.Name] = hopper
+		default:
+			return hoppers, fmt.Errorf("pool %q has unknown type %q", pool.Name, pool.Type)
+		}
+	}
+
+	lookup := func(slot int, name string) (Hopper, error) {
+		hopper, ok := pools[name]
+		if !ok {
+			return nil, fmt.Errorf("slot %d uses unknown pool %q", slot, name)
+		}
+		return hopper, nil
+	}
+
+	for i, slot := range cfg.Slots {
+		if slot.Pool != "" && len(slot.Options) > 0 {
+			return hoppers, fmt.Errorf("slot %d has both a pool and options", i)
+		}
+		if slot.Pool != "" {
+			hopper, err := lookup(i, slot.Pool)
+			if err != nil {
+				return hoppers, err
+			}
+			hoppers[i] = hopper
+			continue
+		}
+		if len(slot.Options) == 0 {
+			return hoppers, fmt.Errorf("slot %d has no pool or options", i)
+		}
+		var options []Hopper
+		var weights []float64
+		for _, option := range slot.Options {
+			hopper, err := lookup(i, option.Pool)
+			if err != nil {
+				return hoppers, err
+			}
+			if _, ok := hopper.(*BalancedHopper); ok {
+				return hoppers, fmt.Errorf("slot %d can't use balanced pool %q as an option", i, option.Pool)
+			}
+			if option.Weight <= 0 {
+				return hoppers, fmt.Errorf("slot %d option %q needs a positive weight", i, option.Pool)
+			}
+			options = append(options, hopper)
+			weights = append(weights, option.Weight)
+		}
+		hoppers[i] = MakeWeightedHopper(rng, options, weights)
+	}
+
+	return hoppers, nil
+}
+
+// makeBalancedHopper checks a balanced pool's settings before creating its hopper.
+func makeBalancedHopper(pool *PoolDefinition, cards []Card, perPack int, infos cardInfos, rng *rand.Rand) (*BalancedHopper, error) {
+	var identity bool
+	switch pool.BalanceBy {
+	case "", BalanceByColor:
+	case BalanceByColorIdentity:
+		identity = true
+	default:
+		return nil, fmt.Errorf("pool %q has unknown balance_by %q", pool.Name, pool.BalanceBy)
+	}
+
+	var counts map[string]int
+	if len(pool.Balance) > 0 {
+		counts = pool.Balance
+		groups := make(map[string]int)
+		for _, card := range cards {
+			groups[balanceGroup(card, infos.get(card), identity)]++
+		}
+		total := 0
+		for group, count := range pool.Balance {
+			if !containsString(BalanceGroups, group) {
+				return nil, fmt.Errorf("pool %q balances unknown group %q", pool.Name, group)
+			}
+			if count < 0 || groups[group] < count {
+				return nil, fmt.Errorf("pool %q has %d %s cards, not enough for %d per pack", pool.Name, groups[group], group, count)
+			}
+			total += count
+		}
+		if total != perPack {
+			return nil, fmt.Errorf("pool %q balances %d cards but %d slots use it", pool.Name, total, perPack)
+		}
+	}
+
+	return newBalancedHopper(rng, infos, pool.Refill, identity, counts, perPack, cards), nil
+}
+
+// sheetCards looks up the cards on every print sheet.
+func sheetCards(cfg *DraftConfig) (map[string][]Card, error) {
+	byID := make(map[string]Card)
+	for _, card := range cfg.Cards {
+		byID[card.ID] = card
+	}
+	sheets := make(map[string][]Card)
+	for _, sheet := range cfg.Sheets {
+		if _, ok := sheets[sheet.Name]; ok {
+			return nil, fmt.Errorf("sheet %q is defined more than once", sheet.Name)
+		}
+		var cards []Card
+		for _, id := range sheet.Cards {
+			card, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("sheet %q has unknown card %q", sheet.Name, id)
+			}
+			cards = append(cards, card)
+		}
+		sheets[sheet.Name] = cards
+	}
+	return sheets, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package makedraft
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testCard makes a card with just enough scryfall data for rules and hoppers.
+func testCard(id string, rarity string, color string, typeLine string, cmc float64) Card {
+	data, err := CardData{Scryfall: ScryfallData{Name: id, TypeLine: typeLine, Cmc: cmc, Rarity: rarity}}.Encode()
+	if err != nil {
+		panic(err)
+	}
+	return Card{ID: id, Rarity: rarity, Color: color, ColorIdentity: color, Data: data}
+}
+
+// testCards makes n creatures of a rarity and color, named after both.
+func testCards(n int, rarity string, color string) []Card {
+	var cards []Card
+	for i := 0; i < n; i++ {
+		cards = append(cards, testCard(fmt.Sprintf("%s-%s-%d", rarity, color, i), rarity, color, "Creature", 2))
+	}
+	return cards
+}
+
+// testSlotConfig has a rare, 13 commons and a basic land in every pack.
+func testSlotConfig() DraftConfig {
+	var cards []Card
+	cards = append(cards, testCards(10, "rare", "W")...)
+	for _, color := range []string{"W", "U", "B", "R", "G"} {
+		cards = append(cards, testCards(10, "common", color)...)
+	}
+	cards = append(cards, testCard("plains", "basic", "", "Basic Land — Plains", 0))
+
+	cfg := DraftConfig{
+		Pools: []PoolDefinition{
+			{Name: "rare", Sources: []PoolSource{{Rarity: []string{"rare"}}}},
+			{Name: "common", Refill: true, Sources: []PoolSource{{Rarity: []string{"common"}}}},
+			{Name: "land", Type: PoolTypeBasic, Sources: []PoolSource{{Rarity: []string{"basic"}}}},
+		},
+		Cards: cards,
+	}
+	cfg.Slots = append(cfg.Slots, SlotDefinition{Pool: "rare"})
+	for i := 0; i < 13; i++ {
+		cfg.Slots = append(cfg.Slots, SlotDefinition{Pool: "common"})
+	}
+	cfg.Slots = append(cfg.Slots, SlotDefinition{Pool: "land"})
+	return cfg
+}
+
+func TestMakeSlotHoppers(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(cfg *DraftConfig)
+		check   func(t *testing.T, hoppers [15]Hopper)
+		wantErr string
+	}{
+		{
+			name: "slots share their pool",
+			check: func(t *testing.T, hoppers [15]Hopper) {
+				if _, ok := hoppers[0].(*NormalHopper); !ok {
+					t.Errorf("slot 0 is a %T, want *NormalHopper", hoppers[0])
+				}
+				if hoppers[1] != hoppers[13] {
+					t.Errorf("slots 1 and 13 use the same pool but got different hoppers")
+				}
+				if _, ok := hoppers[14].(*BasicLandHopper); !ok {
+					t.Errorf("slot 14 is a %T, want *BasicLandHopper", hoppers[14])
+				}
+			},
+		},
+		{
+			name: "pool sources pick cards",
+			check: func(t *testing.T, hoppers [15]Hopper) {
+				rares := hoppers[0].(*NormalHopper)
+				if len(rares.Cards) != 10 {
+					t.Errorf("rare pool has %d cards, want 10", len(rares.Cards))
+				}
+				for _, card := range rares.Cards {
+					if card.Rarity != "rare" {
+						t.Errorf("rare pool has a %s", card.Rarity)
+					}
+				}
+				if commons := hoppers[1].(*NormalHopper); len(commons.Cards) != 50 || !commons.Refillable {
+					t.Errorf("common pool has %d cards and refill %v, want 50 and true", len(commons.Cards), commons.Refillable)
+				}
+			},
+		},
+		{
+			name: "copies",
+			change: func(cfg *DraftConfig) {
+				cfg.Pools[0].Sources[0].Copies = 3
+			},
+			check: func(t *testing.T, hoppers [15]Hopper) {
+				if n := len(hoppers[0].(*NormalHopper).Cards); n != 30 {
+					t.Errorf("rare pool has %d cards, want 30", n)
+				}
+			},
+		},
+		{
+			name: "foil pool",
+			change: func(cfg *DraftConfig) {
+				cfg.Pools[0].Foil = true
+			},
+			check: func(t *testing.T, hoppers [15]Hopper) {
+				card, _ := hoppers[0].Pop()
+				if !card.Foil {
+					t.Errorf("card from a foil pool isn't foil")
+				}
+				card, _ = hoppers[1].Pop()
+				if card.Foil {
+					t.Errorf("card from a non-foil pool is foil")
+				}
+			},
+		},
+		{
+			name: "weighted options",
+			change: func(cfg *DraftConfig) {
+				cfg.Pools = append(cfg.Pools, PoolDefinition{Name: "mythic", Sources: []PoolSource{{IDs: []string{"rare-W-0"}}}})
+				cfg.Slots[0] = SlotDefinition{Options: []SlotOption{{Pool: "rare", Weight: 7}, {Pool: "mythic", Weight: 1}}}
+			},
+			check: func(t *testing.T, hoppers [15]Hopper) {
+				weighted, ok := hoppers[0].(*WeightedHopper)
+				if !ok {
+					t.Fatalf("slot 0 is a %T, want *WeightedHopper", hoppers[0])
+				}
+				if len(weighted.Hoppers) != 2 || weighted.total != 8 {
+					t.Errorf("got %d options with total weight %v, want 2 and 8", len(weighted.Hoppers), weighted.total)
+				}
+			},
+		},
+		{
+			name: "sheet pool",
+			change: func(cfg *DraftConfig) {
+				cfg.Sheets = []SheetDefinition{{Name: "c", Cards: []string{"common-W-0", "common-U-0", "common-B-0"}}}
+				cfg.Pools[1] = PoolDefinition{Name: "common", Type: PoolTypeSheet, Sheet: "c"}
+			},
+			check: func(t *testing.T, hoppers [15]Hopper) {
+				sheet, ok := hoppers[1].(*SheetHopper)
+				if !ok {
+					t.Fatalf("slot 1 is a %T, want *SheetHopper", hoppers[1])
+				}
+				if len(sheet.Sheet) != 3 {
+					t.Errorf("sheet has %d cards, want 3", len(sheet.Sheet))
+				}
+			},
+		},
+		{
+			name:    "too few slots",
+			change:  func(cfg *DraftConfig) { cfg.Slots = cfg.Slots[:14] },
+			wantErr: "packs need exactly 15 slots, not 14",
+		},
+		{
+			name:    "pool defined twice",
+			change:  func(cfg *DraftConfig) { cfg.Pools = append(cfg.Pools, cfg.Pools[0]) },
+			wantErr: `pool "rare" is defined more than once`,
+		},
+		{
+			name:    "pool with no cards",
+			change:  func(cfg *DraftConfig) { cfg.Pools[0].Sources[0].Rarity = []string{"mythic"} },
+			wantErr: `pool "rare" has no cards`,
+		},
+		{
+			name:    "unknown pool type",
+			change:  func(cfg *DraftConfig) { cfg.Pools[0].Type = "magic" },
+			wantErr: `pool "rare" has unknown type "magic"`,
+		},
+		{
+			name:    "unknown sheet",
+			change:  func(cfg *DraftConfig) { cfg.Pools[1] = PoolDefinition{Name: "common", Type: PoolTypeSheet, Sheet: "c"} },
+			wantErr: `pool "common" uses unknown sheet "c"`,
+		},
+		{
+			name: "sheet defined twice",
+			change: func(cfg *DraftConfig) {
+				cfg.Sheets = []SheetDefinition{{Name: "c", Cards: []string{"plains"}}, {Name: "c", Cards: []string{"plains"}}}
+			},
+			wantErr: `sheet "c" is defined more than once`,
+		},
+		{
+			name:    "sheet with an unknown card",
+			change:  func(cfg *DraftConfig) { cfg.Sheets = []SheetDefinition{{Name: "c", Cards: []string{"island"}}} },
+			wantErr: `sheet "c" has unknown card "island"`,
+		},
+		{
+			name:    "slot with an unknown pool",
+			change:  func(cfg *DraftConfig) { cfg.Slots[3].Pool = "uncommon" },
+			wantErr: `slot 3 uses unknown pool "uncommon"`,
+		},
+		{
+			name: "slot with a pool and options",
+			change: func(cfg *DraftConfig) {
+				cfg.Slots[0].Options = []SlotOption{{Pool: "common", Weight: 1}}
+			},
+			wantErr: "slot 0 has both a pool and options",
+		},
+		{
+			name:    "slot with neither",
+			change:  func(cfg *DraftConfig) { cfg.Slots[2] = SlotDefinition{} },
+			wantErr: "slot 2 has no pool or options",
+		},
+		{
+			name: "option without a weight",
+			change: func(cfg *DraftConfig) {
+				cfg.Slots[0] = SlotDefinition{Options: []SlotOption{{Pool: "rare", Weight: 0}}}
+			},
+			wantErr: `slot 0 option "rare" needs a positive weight`,
+		},
+		{
+			name: "balanced pool as an option",
+			change: func(cfg *DraftConfig) {
+				cfg.Pools[1].Type = PoolTypeBalanced
+				cfg.Slots[0] = SlotDefinition{Options: []SlotOption{{Pool: "common", Weight: 1}}}
+			},
+			wantErr: `slot 0 can't use balanced pool "common" as an option`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := testSlotConfig()
+			if test.change != nil {
+				test.change(&cfg)
+			}
+			hoppers, err := makeSlotHoppers(&cfg, nil, rand.New(rand.NewSource(1)))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("makeSlotHoppers() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("makeSlotHoppers() error = %v", err)
+			}
+			test.check(t, hoppers)
+		})
+	}
+}
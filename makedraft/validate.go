@@ -0,0 +1,322 @@
+package makedraft
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// ValidationProblem is something wrong with a set json file, and where it is.
+type ValidationProblem struct {
+	Location string
+	Problem  string
+}
+
+func (p ValidationProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Location, p.Problem)
+}
+
+// knownRarities are the rarities cards can have, in the order they're listed.
+var knownRarities = []string{"mythic", "rare", "uncommon", "common", "basic"}
+
+// legacyHopperRarities lists the rarities each legacy hopper type draws from.
+// A hopper with no cards to draw from panics when popped.
+var legacyHopperRarities = map[string][]string{
+	"RareHopper":           {"mythic", "rare"},
+	"RareRefillHopper":     {"mythic", "rare"},
+	"UncommonHopper":       {"uncommon"},
+	"UncommonRefillHopper": {"uncommon"},
+	"CommonHopper":         {"common"},
+	"CommonRefillHopper":   {"common"},
+	"BasicLandHopper":      {"basic"},
+	"CubeHopper":           knownRarities,
+	"DfcHopper":            {"mythic", "rare", "uncommon", "common"},
+	"DfcRefillHopper":      {"mythic", "rare", "uncommon", "common"},
+	"FoilHopper":           knownRarities,
+	"Pointer":              nil,
+}
+
+// Validate checks everything in a set json file that would otherwise only fail,
+// or panic, while generating a draft. It returns every problem it finds.
+func Validate(cfg DraftConfig) []ValidationProblem {
+	var problems []ValidationProblem
+	report := func(location string, format string, args ...interface{}) {
+		problems = append(problems, ValidationProblem{Location: location, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	settings := DefaultSettings()
+	flagArgs, err := cfg.FlagArgs()
+	if err != nil {
+		report("flags", "%s", err.Error())
+	} else if err = settings.ApplyFlags(flagArgs); err != nil {
+		report("flags", "%s", err.Error())
+	}
+
+	usesFoils := false
+	for _, pool := range cfg.Pools {
+		usesFoils = usesFoils || pool.Foil
+	}
+	for _, hopdef := range cfg.Hoppers {
+		usesFoils = usesFoils || hopdef.Type == "FoilHopper"
+	}
+
+	validateCards(&cfg, usesFoils, report)
+
+	switch {
+	case len(cfg.Slots) > 0:
+		if len(cfg.Hoppers) > 0 {
+			report("hoppers", "hoppers are ignored when slots are given")
+		}
+		validateSlots(&cfg, report)
+	case len(cfg.Hoppers) > 0:
+		validateHoppers(&cfg, &settings, report)
+	default:
+		report("slots", "the set has no slots or hoppers")
+	}
+
+	for i, def := range cfg.Rules {
+		if _, err := MakeRule(def); err != nil {
+			report(fmt.Sprintf("rules[%d]", i), "%s", err.Error())
+		}
+	}
+
+	return problems
+}
+
+type reportFunc func(location string, format string, args ...interface{})
+
+func validateCards(cfg *DraftConfig, usesFoils bool, report reportFunc) {
+	if len(cfg.Cards) == 0 {
+		report("cards", "the set has no cards")
+	}
+	ids := make(map[string]int)
+	for i, card := range cfg.Cards {
+		location := fmt.Sprintf("cards[%d]", i)
+		if card.ID == "" {
+			report(location, "card has no id")
+		} else if first, ok := ids[card.ID]; ok {
+			report(location, "id %s is already used by cards[%d]", card.ID, first)
+		} else {
+			ids[card.ID] = i
+		}
+		if !containsString(knownRarities, card.Rarity) {
+			report(location, "unknown rarity %q", card.Rarity)
+		}
+		for j, colors := range []string{card.Color, card.ColorIdentity} {
+			for _, color := range colors {
+				if !strings.ContainsRune("WUBRG", color) {
+					report(location, "%s %q has a color that isn't one of WUBRG", []string{"color", "color_identity"}[j], colors)
+					break
+				}
+			}
+		}
+
+		data, err := ParseCardData(card.Data)
+		if err != nil {
+			report(location, "data isn't valid: %s", err.Error())
+			continue
+		}
+		if data.Scryfall.Name != "" {
+			location = fmt.Sprintf("%s (%s)", location, data.Scryfall.Name)
+		} else {
+			report(location, "data has no scryfall name")
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(card.Data))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&CardData{}); err != nil {
+			report(location, "data will lose a field that r38 doesn't know: %s", err.Error())
+		}
+
+		var foil struct {
+			Foil json.RawMessage `json:"foil"`
+		}
+		json.Unmarshal([]byte(card.Data), &foil)
+		if foil.Foil == nil {
+			report(location, "data has no foil")
+		} else if usesFoils && !data.Foil.FromSlot {
+			report(location, "foil is always %v, so foil copies won't show as foil. use %q", data.Foil.Foil, FoilStatus)
+		}
+	}
+}
+
+func validateSlots(cfg *DraftConfig, report reportFunc) {
+	ids := make(map[string]bool)
+	for _, card := range cfg.Cards {
+		ids[card.ID] = true
+	}
+
+	sheets := make(map[string]bool)
+	for i, sheet := range cfg.Sheets {
+		location := fmt.Sprintf("sheets[%d] (%s)", i, sheet.Name)
+		if sheet.Name == "" {
+			report(location, "sheet has no name")
+		} else if sheets[sheet.Name] {
+			report(location, "sheet %q is defined more than once", sheet.Name)
+		}
+		sheets[sheet.Name] = true
+		if len(sheet.Cards) == 0 {
+			report(location, "sheet has no cards")
+		}
+		for j, id := range sheet.Cards {
+			if !ids[id] {
+				report(fmt.Sprintf("%s.cards[%d]", location, j), "unknown card %q", id)
+			}
+		}
+	}
+
+	pools := make(map[string]bool)
+	balanced := make(map[string]bool)
+	for i := range cfg.Pools {
+		pool := &cfg.Pools[i]
+		location := fmt.Sprintf("pools[%d] (%s)", i, pool.Name)
+		if pool.Name == "" {
+			report(location, "pool has no name")
+		} else if pools[pool.Name] {
+			report(location, "pool %q is defined more than once", pool.Name)
+		}
+		pools[pool.Name] = true
+
+		switch pool.Type {
+		case "", PoolTypeNormal, PoolTypeBasic, PoolTypeBalanced:
+			if pool.Sheet != "" {
+				report(location, "only sheet pools use a sheet")
+			}
+			for j, source := range pool.Sources {
+				for _, rarity := range source.Rarity {
+					if !containsString(knownRarities, rarity) {
+						report(fmt.Sprintf("%s.sources[%d]", location, j), "unknown rarity %q", rarity)
+					}
+				}
+				if source.Copies < 0 {
+					report(fmt.Sprintf("%s.sources[%d]", location, j), "copies can't be negative")
+				}
+			}
+			cards := poolCards(pool, cfg.Cards)
+			if len(cards) == 0 {
+				report(location, "pool has no cards")
+			}
+			if pool.Type == PoolTypeBalanced {
+				balanced[pool.Name] = true
+				perPack := 0
+				for _, slot := range cfg.Slots {
+					if slot.Pool == pool.Name {
+						perPack++
+					}
+				}
+				if _, err := makeBalancedHopper(pool, cards, perPack, nil, rand.New(rand.NewSource(0))); err != nil {
+					report(location, "%s", err.Error())
+				}
+			} else if len(pool.Balance) > 0 || pool.BalanceBy != "" {
+				report(location, "only balanced pools use balance and balance_by")
+			}
+		case PoolTypeSheet:
+			if !sheets[pool.Sheet] {
+				report(location, "unknown sheet %q", pool.Sheet)
+			}
+			if len(pool.Sources) > 0 {
+				report(location, "sheet pools take their cards from the sheet, not sources")
+			}
+		default:
+			report(location, "unknown type %q", pool.Type)
+		}
+	}
+
+	if len(cfg.Slots) != 15 {
+		report("slots", "packs need exactly 15 slots, not %d", len(cfg.Slots))
+	}
+	for i, slot := range cfg.Slots {
+		location := fmt.Sprintf("slots[%d]", i)
+		if slot.Pool != "" && len(slot.Options) > 0 {
+			report(location, "slot has both a pool and options")
+		} else if slot.Pool == "" && len(slot.Options) == 0 {
+			report(location, "slot has no pool or options")
+		}
+		if slot.Pool != "" && !pools[slot.Pool] {
+			report(location, "unknown pool %q", slot.Pool)
+		}
+		for j, option := range slot.Options {
+			if !pools[option.Pool] {
+				report(fmt.Sprintf("%s.options[%d]", location, j), "unknown pool %q", option.Pool)
+			}
+			if option.Weight <= 0 {
+				report(fmt.Sprintf("%s.options[%d]", location, j), "weight must be positive")
+			}
+			if balanced[option.Pool] {
+				report(fmt.Sprintf("%s.options[%d]", location, j), "balanced pool %q can't be an option", option.Pool)
+			}
+		}
+	}
+}
+
+func validateHoppers(cfg *DraftConfig, settings *Settings, report reportFunc) {
+	if len(cfg.Hoppers) != 15 {
+		report("hoppers", "packs need exactly 15 hoppers, not %d", len(cfg.Hoppers))
+	}
+
+	// validateCards already reports unknown rarities, so only count cards without them.
+	allCards, dfcCards, err := splitCards(cfg.Cards, settings)
+	countCards := err == nil
+	count := func(cards *CardSet, rarity string) int {
+		switch rarity {
+		case "mythic":
+			return len(cards.Mythics)
+		case "rare":
+			return len(cards.Rares)
+		case "uncommon":
+			return len(cards.Uncommons)
+		case "common":
+			return len(cards.Commons)
+		case "basic":
+			return len(cards.Basics)
+		}
+		return 0
+	}
+
+	for i, hopdef := range cfg.Hoppers {
+		location := fmt.Sprintf("hoppers[%d] (%s)", i, hopdef.Type)
+		rarities, ok := legacyHopperRarities[hopdef.Type]
+		if !ok {
+			report(location, "unknown hopper type %q", hopdef.Type)
+			continue
+		}
+
+		cards := &allCards
+		if strings.HasPrefix(hopdef.Type, "Dfc") {
+			cards = &dfcCards
+			if !settings.DfcMode {
+				report(location, "DFC hoppers need -dfc-mode")
+			}
+		}
+		total := 0
+		for _, rarity := range rarities {
+			total += count(cards, rarity)
+		}
+		if countCards && rarities != nil && total == 0 {
+			report(location, "hopper has no cards")
+		}
+
+		refs := 0
+		switch hopdef.Type {
+		case "Pointer":
+			refs = 1
+		case "FoilHopper":
+			refs = 3
+		}
+		if len(hopdef.Refs) != refs {
+			report(location, "needs %d refs, not %d", refs, len(hopdef.Refs))
+			continue
+		}
+		for _, ref := range hopdef.Refs {
+			switch {
+			case ref < 0 || ref >= 15 || ref >= int64(len(cfg.Hoppers)):
+				report(location, "ref %d is out of range", ref)
+			case ref == int64(i):
+				report(location, "ref %d points at itself", ref)
+			case hopdef.Type == "Pointer" && ref > int64(i):
+				report(location, "ref %d must point at an earlier hopper", ref)
+			}
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package makedraft
+
+import (
+	"strings"
+	"testing"
+)
+
+// testHopperConfig is testSlotConfig described with legacy hoppers instead of slots.
+func testHopperConfig() DraftConfig {
+	cfg := testSlotConfig()
+	cfg.Pools, cfg.Slots = nil, nil
+	cfg.Hoppers = append(cfg.Hoppers, HopperDefinition{Type: "RareHopper"})
+	cfg.Hoppers = append(cfg.Hoppers, HopperDefinition{Type: "CommonRefillHopper"})
+	for i := 0; i < 12; i++ {
+		cfg.Hoppers = append(cfg.Hoppers, HopperDefinition{Type: "Pointer", Refs: []int64{1}})
+	}
+	cfg.Hoppers = append(cfg.Hoppers, HopperDefinition{Type: "BasicLandHopper"})
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func() DraftConfig
+		change  func(cfg *DraftConfig)
+		want    string
+		wantLoc string
+	}{
+		{name: "valid slots", config: testSlotConfig},
+		{name: "valid hoppers", config: testHopperConfig},
+		{
+			name:    "bad flag",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Flags = []string{"--no-such-flag"} },
+			wantLoc: "flags",
+			want:    "no-such-flag",
+		},
+		{
+			name:    "no cards",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Cards = nil },
+			wantLoc: "cards",
+			want:    "the set has no cards",
+		},
+		{
+			name:    "duplicate id",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Cards[1].ID = cfg.Cards[0].ID },
+			wantLoc: "cards[1]",
+			want:    "is already used by cards[0]",
+		},
+		{
+			name:    "unknown rarity",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Cards[0].Rarity = "special" },
+			wantLoc: "cards[0]",
+			want:    `unknown rarity "special"`,
+		},
+		{
+			name:    "bad color",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Cards[0].Color = "WX" },
+			wantLoc: "cards[0]",
+			want:    `color "WX" has a color that isn't one of WUBRG`,
+		},
+		{
+			name:    "bad data",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Cards[0].Data = "{" },
+			wantLoc: "cards[0]",
+			want:    "data isn't valid",
+		},
+		{
+			name:   "unknown field",
+			config: testSlotConfig,
+			change: func(cfg *DraftConfig) {
+				cfg.Cards[0].Data = `{"foil": false, "scryfall": {"name": "x"}, "flavor": "y"}`
+			},
+			wantLoc: "cards[0] (x)",
+			want:    "data will lose a field",
+		},
+		{
+			name:    "no foil",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Cards[0].Data = `{"scryfall": {"name": "x"}}` },
+			wantLoc: "cards[0] (x)",
+			want:    "data has no foil",
+		},
+		{
+			name:    "fixed foil in a foil set",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Pools[0].Foil = true },
+			wantLoc: "cards[0] (rare-W-0)",
+			want:    "foil copies won't show as foil",
+		},
+		{
+			name:    "no slots or hoppers",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Slots = nil },
+			wantLoc: "slots",
+			want:    "the set has no slots or hoppers",
+		},
+		{
+			name:    "slots and hoppers",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers = testHopperConfig().Hoppers },
+			wantLoc: "hoppers",
+			want:    "hoppers are ignored when slots are given",
+		},
+		{
+			name:    "pool without cards",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Pools[0].Sources[0].Rarity = []string{"mythic"} },
+			wantLoc: "pools[0] (rare)",
+			want:    "pool has no cards",
+		},
+		{
+			name:    "negative copies",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Pools[0].Sources[0].Copies = -1 },
+			wantLoc: "pools[0] (rare).sources[0]",
+			want:    "copies can't be negative",
+		},
+		{
+			name:    "balance on a normal pool",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Pools[1].BalanceBy = BalanceByColor },
+			wantLoc: "pools[1] (common)",
+			want:    "only balanced pools use balance and balance_by",
+		},
+		{
+			name:   "balanced pool that can't fill its slots",
+			config: testSlotConfig,
+			change: func(cfg *DraftConfig) {
+				cfg.Pools[1].Type = PoolTypeBalanced
+				cfg.Pools[1].Balance = map[string]int{"W": 13}
+			},
+			wantLoc: "pools[1] (common)",
+			want:    "not enough for 13 per pack",
+		},
+		{
+			name:    "sheet pool with an unknown sheet",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Pools[1] = PoolDefinition{Name: "common", Type: PoolTypeSheet, Sheet: "c"} },
+			wantLoc: "pools[1] (common)",
+			want:    `unknown sheet "c"`,
+		},
+		{
+			name:    "sheet with an unknown card",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Sheets = []SheetDefinition{{Name: "c", Cards: []string{"island"}}} },
+			wantLoc: "sheets[0] (c).cards[0]",
+			want:    `unknown card "island"`,
+		},
+		{
+			name:    "unknown pool type",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Pools[0].Type = "magic" },
+			wantLoc: "pools[0] (rare)",
+			want:    `unknown type "magic"`,
+		},
+		{
+			name:    "too few slots",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Slots = cfg.Slots[:3] },
+			wantLoc: "slots",
+			want:    "packs need exactly 15 slots, not 3",
+		},
+		{
+			name:    "slot with an unknown pool",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Slots[2].Pool = "uncommon" },
+			wantLoc: "slots[2]",
+			want:    `unknown pool "uncommon"`,
+		},
+		{
+			name:   "option without a weight",
+			config: testSlotConfig,
+			change: func(cfg *DraftConfig) {
+				cfg.Slots[0] = SlotDefinition{Options: []SlotOption{{Pool: "rare"}}}
+			},
+			wantLoc: "slots[0].options[0]",
+			want:    "weight must be positive",
+		},
+		{
+			name:    "bad rule",
+			config:  testSlotConfig,
+			change:  func(cfg *DraftConfig) { cfg.Rules = []RuleDefinition{{Rule: RuleCreatureCount}} },
+			wantLoc: "rules[0]",
+			want:    "needs min or max",
+		},
+		{
+			name:    "too few hoppers",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers = cfg.Hoppers[:14] },
+			wantLoc: "hoppers",
+			want:    "packs need exactly 15 hoppers, not 14",
+		},
+		{
+			name:    "unknown hopper",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers[0].Type = "MagicHopper" },
+			wantLoc: "hoppers[0] (MagicHopper)",
+			want:    `unknown hopper type "MagicHopper"`,
+		},
+		{
+			name:    "hopper without cards",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers[0].Type = "UncommonHopper" },
+			wantLoc: "hoppers[0] (UncommonHopper)",
+			want:    "hopper has no cards",
+		},
+		{
+			name:    "dfc hopper outside dfc mode",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers[0].Type = "DfcHopper" },
+			wantLoc: "hoppers[0] (DfcHopper)",
+			want:    "DFC hoppers need -dfc-mode",
+		},
+		{
+			name:    "pointer without a ref",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers[2].Refs = nil },
+			wantLoc: "hoppers[2] (Pointer)",
+			want:    "needs 1 refs, not 0",
+		},
+		{
+			name:    "pointer forward",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers[2].Refs = []int64{5} },
+			wantLoc: "hoppers[2] (Pointer)",
+			want:    "must point at an earlier hopper",
+		},
+		{
+			name:    "pointer at itself",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers[2].Refs = []int64{2} },
+			wantLoc: "hoppers[2] (Pointer)",
+			want:    "points at itself",
+		},
+		{
+			name:    "ref out of range",
+			config:  testHopperConfig,
+			change:  func(cfg *DraftConfig) { cfg.Hoppers[2].Refs = []int64{15} },
+			wantLoc: "hoppers[2] (Pointer)",
+			want:    "ref 15 is out of range",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := test.config()
+			if test.change != nil {
+				test.change(&cfg)
+			}
+			problems := Validate(cfg)
+			if test.want == "" {
+				if len(problems) > 0 {
+					t.Errorf("Validate() = %v, want no problems", problems)
+				}
+				return
+			}
+			for _, problem := range problems {
+				if problem.Location == test.wantLoc && strings.Contains(problem.Problem, test.want) {
+					return
+				}
+			}
+			t.Errorf("Validate() = %v, want %s: %s", problems, test.wantLoc, test.want)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "r38_http_requests_total",
+		Help: "HTTP requests handled, by route and status code.",
+	}, []string{"route", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "r38_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+	picksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "r38_picks_total",
+		Help: "Picks made. Use rate() for picks per minute.",
+	})
+	notificationFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "r38_notification_failures_total",
+		Help: "Discord notifications that could not be sent.",
+	})
+	rollbacksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "r38_transaction_rollbacks_total",
+		Help: "Request transactions rolled back because the handler failed, by route.",
+	}, []string{"route"})
+)
+
+// HealthStatus is the JSON returned by /healthz and /readyz.
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it.
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// instrument records request count and latency for a route.
+func instrument(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		requestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		requestsTotal.WithLabelValues(route, strconv.Itoa(recorder.status)).Inc()
+	})
+}
+
+// NewMetricsHandler serves /metrics for everything r38 measures.
+func NewMetricsHandler(database *sql.DB) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		picksTotal,
+		notificationFailuresTotal,
+		rollbacksTotal,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "r38_active_drafts",
+			Help: "Drafts with at least one player that aren't finished yet.",
+		}, func() float64 {
+			activeDrafts, err := countActiveDrafts(database)
+			if err != nil {
+				log.Printf("error counting active drafts: %s", err.Error())
+				return 0
+			}
+			return float64(activeDrafts)
+		}),
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// requireMetricsToken only lets through requests that send token as a bearer token.
+func requireMetricsToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// countActiveDrafts counts drafts that someone has joined and that still have picks left.
+func countActiveDrafts(database *sql.DB) (int64, error) {
+	query := `select
+                    count(1)
+                  from drafts
+                  where exists (select 1 from seats where seats.draft = drafts.id and seats.user is not null)
+                    and exists (select 1 from seats where seats.draft = drafts.id and seats.round < 4)`
+	ctx, cancel := context.WithTimeout(context.Background(), config.RequestTimeout)
+	defer cancel()
+	var activeDrafts int64
+	err := database.QueryRowContext(ctx, query).Scan(&activeDrafts)
+	return activeDrafts, err
+}
+
+// ServeHealthz reports that the process is up. It doesn't check any dependencies.
+func ServeHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HealthStatus{Status: "ok"})
+}
+
+// filterCheckInterval is how long /readyz reuses the result of dialing the filter
+// backend, so frequent probes don't open a connection every time.
+const filterCheckInterval = 5 * time.Second
+
+// filterCheck dials the filter backend at most once per filterCheckInterval.
+type filterCheck struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// check returns the error from the last dial, dialing again if it's too old.
+func (c *filterCheck) check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checked.IsZero() && time.Since(c.checked) < filterCheckInterval {
+		return c.err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", config.FilterSocket)
+	if err == nil {
+		conn.Close()
+	}
+	c.checked = time.Now()
+	c.err = err
+	return err
+}
+
+// NewReadyzHandler reports whether the database and the filter backend are reachable.
+func NewReadyzHandler(database *sql.DB) http.Handler {
+	var filter filterCheck
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout)
+		defer cancel()
+
+		status := HealthStatus{Status: "ok", Checks: map[string]string{"database": "ok", "filter": "ok"}}
+
+		err := database.PingContext(ctx)
+		if err != nil {
+			status.Status = "unavailable"
+			status.Checks["database"] = err.Error()
+		}
+
+		err = filter.check(ctx)
+		if err != nil {
+			status.Status = "unavailable"
+			status.Checks["filter"] = err.Error()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if status.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
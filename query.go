@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CardQuery is a parsed card search, like `delver t:creature c:u cmc<=2 r:common s:isd`.
+// Every term has to match. Words on their own, or "quoted phrases", search card names.
+//
+//	name: n:       the name contains the value
+//	type: t:       the type line contains the value
+//	color: c:      the card has all of these colors; c=wu is exactly white and blue, c:c is colorless
+//	rarity: r:     common, uncommon, rare, mythic or basic, or just their first letter
+//	cmc mv         compared with : = != < <= > >=
+//	set: s: e:     the printing's set code
+//
+// Any term can be negated with a leading -.
+type CardQuery struct {
+	Terms []QueryTerm
+}
+
+// QueryTerm is one term of a CardQuery. Field is always the long name of the field.
+type QueryTerm struct {
+	Field  string
+	Op     string
+	Value  string
+	Negate bool
+}
+
+var queryFields = map[string]string{
+	"name":    "name",
+	"n":       "name",
+	"type":    "type",
+	"t":       "type",
+	"color":   "color",
+	"c":       "color",
+	"rarity":  "rarity",
+	"r":       "rarity",
+	"cmc":     "cmc",
+	"mv":      "cmc",
+	"set":     "set",
+	"s":       "set",
+	"e":       "set",
+	"edition": "set",
+}
+
+var queryRarities = map[string]string{
+	"c": "common",
+	"u": "uncommon",
+	"r": "rare",
+	"m": "mythic",
+	"b": "basic",
+}
+
+var queryTermRegexp = regexp.MustCompile(`^([a-zA-Z]+)(:|!=|<=|>=|=|<|>)(.+)$`)
+
+// ParseCardQuery parses a card search.
+func ParseCardQuery(q string) (CardQuery, error) {
+	var query CardQuery
+	tokens, err := queryTokens(q)
+	if err != nil {
+		return query, err
+	}
+	if len(tokens) == 0 {
+		return query, fmt.Errorf("empty search")
+	}
+
+	for _, token := range tokens {
+		var term QueryTerm
+		if len(token) > 1 && token[0] == '-' {
+			term.Negate = true
+			token = token[1:]
+		}
+
+		match := queryTermRegexp.FindStringSubmatch(token)
+		if token[0] == '"' || match == nil {
+			term.Field = "name"
+			term.Op = ":"
+			term.Value = strings.Trim(token, `"`)
+			query.Terms = append(query.Terms, term)
+			continue
+		}
+
+		field, ok := queryFields[strings.ToLower(match[1])]
+		if !ok {
+			return query, fmt.Errorf("unknown search field %q", match[1])
+		}
+		term.Field = field
+		term.Op = match[2]
+		term.Value = strings.ToLower(strings.Trim(match[3], `"`))
+
+		switch field {
+		case "cmc":
+			if _, err := strconv.ParseFloat(term.Value, 64); err != nil {
+				return query, fmt.Errorf("cmc %q isn't a number", term.Value)
+			}
+		case "color":
+			if term.Op != ":" && term.Op != "=" {
+				return query, fmt.Errorf("color can only be searched with : or =")
+			}
+			if term.Value == "colorless" {
+				term.Value = "c"
+			}
+			for _, color := range term.Value {
+				if !strings.ContainsRune("wubrgc", color) {
+					return query, fmt.Errorf("color %q has a color that isn't one of WUBRG or C", term.Value)
+				}
+			}
+			if strings.ContainsRune(term.Value, 'c') && term.Value != "c" {
+				return query, fmt.Errorf("colorless can't be searched with other colors")
+			}
+		case "rarity":
+			if rarity, ok := queryRarities[term.Value]; ok {
+				term.Value = rarity
+			}
+			known := false
+			for _, rarity := range queryRarities {
+				known = known || rarity == term.Value
+			}
+			if !known {
+				return query, fmt.Errorf("unknown rarity %q", term.Value)
+			}
+		}
+		if field != "cmc" && term.Op != ":" && term.Op != "=" {
+			return query, fmt.Errorf("%s can only be searched with : or =", field)
+		}
+		query.Terms = append(query.Terms, term)
+	}
+	return query, nil
+}
+
+// queryTokens splits a search on spaces, keeping "quoted phrases" together.
+func queryTokens(q string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			token.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unclosed quote")
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+// Where returns a condition on the catalog table that matches the query, and its arguments.
+func (q CardQuery) Where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, term := range q.Terms {
+		var condition string
+		switch term.Field {
+		case "name", "type":
+			column := map[string]string{"name": "catalog.name", "type": "catalog.type"}[term.Field]
+			condition = `lower(` + column + `) like ? escape '\'`
+			args = append(args, "%"+escapeLike(strings.ToLower(term.Value))+"%")
+		case "color":
+			if term.Value == "c" {
+				condition = `catalog.color = ''`
+			} else if term.Op == "=" {
+				// the catalog keeps a card's colors with their letters sorted.
+				colors := strings.Split(strings.ToUpper(term.Value), "")
+				sort.Strings(colors)
+				condition = `catalog.color = ?`
+				args = append(args, strings.Join(colors, ""))
+			} else {
+				var colors []string
+				for _, color := range strings.ToUpper(term.Value) {
+					colors = append(colors, `catalog.color like ?`)
+					args = append(args, "%"+string(color)+"%")
+				}
+				condition = strings.Join(colors, " and ")
+			}
+		case "rarity":
+			condition = `catalog.rarity = ?`
+			args = append(args, term.Value)
+		case "set":
+			condition = `catalog.edition = ?`
+			args = append(args, term.Value)
+		case "cmc":
+			op := term.Op
+			if op == ":" {
+				op = "="
+			}
+			value, _ := strconv.ParseFloat(term.Value, 64)
+			condition = `catalog.cmc ` + op + ` ?`
+			args = append(args, value)
+		}
+		if term.Negate {
+			condition = `not (` + condition + `)`
+		}
+		conditions = append(conditions, `(`+condition+`)`)
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCardQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []QueryTerm
+		wantErr string
+	}{
+		{
+			name:  "bare word",
+			query: "Delver",
+			want:  []QueryTerm{{Field: "name", Op: ":", Value: "Delver"}},
+		},
+		{
+			name:  "quoted phrase",
+			query: `"delver of secrets" t:creature`,
+			want:  []QueryTerm{{Field: "name", Op: ":", Value: "delver of secrets"}, {Field: "type", Op: ":", Value: "creature"}},
+		},
+		{
+			name:  "quoted value",
+			query: `t:"legendary creature"`,
+			want:  []QueryTerm{{Field: "type", Op: ":", Value: "legendary creature"}},
+		},
+		{
+			name:  "short and long fields",
+			query: "n:bolt TYPE:Instant e:M11 edition:isd",
+			want: []QueryTerm{
+				{Field: "name", Op: ":", Value: "bolt"},
+				{Field: "type", Op: ":", Value: "instant"},
+				{Field: "set", Op: ":", Value: "m11"},
+				{Field: "set", Op: ":", Value: "isd"},
+			},
+		},
+		{
+			name:  "negated",
+			query: "-c:u -island",
+			want:  []QueryTerm{{Field: "color", Op: ":", Value: "u", Negate: true}, {Field: "name", Op: ":", Value: "island", Negate: true}},
+		},
+		{
+			name:  "lone dash is a name",
+			query: "-",
+			want:  []QueryTerm{{Field: "name", Op: ":", Value: "-"}},
+		},
+		{
+			name:  "cmc comparisons",
+			query: "cmc<=2 mv>1.5 cmc!=3",
+			want: []QueryTerm{
+				{Field: "cmc", Op: "<=", Value: "2"},
+				{Field: "cmc", Op: ">", Value: "1.5"},
+				{Field: "cmc", Op: "!=", Value: "3"},
+			},
+		},
+		{
+			name:  "colorless",
+			query: "c:colorless",
+			want:  []QueryTerm{{Field: "color", Op: ":", Value: "c"}},
+		},
+		{
+			name:  "rarity letter",
+			query: "r:m rarity:Uncommon",
+			want:  []QueryTerm{{Field: "rarity", Op: ":", Value: "mythic"}, {Field: "rarity", Op: ":", Value: "uncommon"}},
+		},
+		{
+			name:  "extra spaces",
+			query: "  t:goblin\t\tc=r  ",
+			want:  []QueryTerm{{Field: "type", Op: ":", Value: "goblin"}, {Field: "color", Op: "=", Value: "r"}},
+		},
+		{name: "empty", query: "   ", wantErr: "empty search"},
+		{name: "unclosed quote", query: `"delver of`, wantErr: "unclosed quote"},
+		{name: "unknown field", query: "pow:3", wantErr: `unknown search field "pow"`},
+		{name: "cmc not a number", query: "cmc:x", wantErr: `cmc "x" isn't a number`},
+		{name: "color compared", query: "c>u", wantErr: "color can only be searched with : or ="},
+		{name: "unknown color", query: "c:wx", wantErr: `color "wx" has a color that isn't one of WUBRG or C`},
+		{name: "colorless with colors", query: "c:wc", wantErr: "colorless can't be searched with other colors"},
+		{name: "unknown rarity", query: "r:special", wantErr: `unknown rarity "special"`},
+		{name: "text compared", query: "t<creature", wantErr: "type can only be searched with : or ="},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseCardQuery(test.query)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ParseCardQuery(%q) error = %v, want %q", test.query, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCardQuery(%q) error = %v", test.query, err)
+			}
+			if !reflect.DeepEqual(got.Terms, test.want) {
+				t.Errorf("ParseCardQuery(%q) = %+v, want %+v", test.query, got.Terms, test.want)
+			}
+		})
+	}
+}
+
+func TestCardQueryWhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		want     string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "name",
+			query:    "Delver",
+			want:     `(lower(catalog.name) like ? escape '\')`,
+			wantArgs: []interface{}{"%delver%"},
+		},
+		{
+			name:     "like wildcards are escaped",
+			query:    `n:100%_a\b`,
+			want:     `(lower(catalog.name) like ? escape '\')`,
+			wantArgs: []interface{}{`%100\%\_a\\b%`},
+		},
+		{
+			name:     "all of these colors",
+			query:    "c:ub",
+			want:     `(catalog.color like ? and catalog.color like ?)`,
+			wantArgs: []interface{}{"%U%", "%B%"},
+		},
+		{
+			name:     "exactly these colors",
+			query:    "c=ub",
+			want:     `(catalog.color = ?)`,
+			wantArgs: []interface{}{"BU"},
+		},
+		{
+			name:  "colorless",
+			query: "c:c",
+			want:  `(catalog.color = '')`,
+		},
+		{
+			name:     "cmc colon is equals",
+			query:    "cmc:2",
+			want:     `(catalog.cmc = ?)`,
+			wantArgs: []interface{}{2.0},
+		},
+		{
+			name:     "several terms",
+			query:    "-r:c s:isd cmc>=3",
+			want:     `(not (catalog.rarity = ?)) and (catalog.edition = ?) and (catalog.cmc >= ?)`,
+			wantArgs: []interface{}{"common", "isd", 3.0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query, err := ParseCardQuery(test.query)
+			if err != nil {
+				t.Fatalf("ParseCardQuery(%q) error = %v", test.query, err)
+			}
+			got, args := query.Where()
+			if got != test.want {
+				t.Errorf("Where() = %q, want %q", got, test.want)
+			}
+			if !reflect.DeepEqual(args, test.wantArgs) {
+				t.Errorf("Where() args = %v, want %v", args, test.wantArgs)
+			}
+		})
+	}
+}
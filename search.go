@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// searchLimit is the most cards a single search returns.
+const searchLimit = 500
+
+// searchView is what a user can see of a draft. If they can't see the whole draft,
+// they can still see their own picks and the pack in front of them. Hidden drafts are
+// private drafts the user isn't in, which they can't search at all.
+type searchView struct {
+	wholeDraft bool
+	packID     int64
+	hidden     bool
+}
+
+// ServeAPISearch serves the /api/search endpoint, which finds cards in every draft,
+// or just ?draft=id, with a query in ?q= (see CardQuery). Drafts that are
+// still running only show what the replay viewer would show the user, and only cards
+// in the catalog are found.
+func ServeAPISearch(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	cardQuery, err := ParseCardQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		return fmt.Errorf("bad search: %s", err.Error())
+	}
+	where, args := cardQuery.Where()
+
+	if r.URL.Query().Get("draft") != "" {
+		draftID, err := strconv.ParseInt(r.URL.Query().Get("draft"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad draft: %s", err.Error())
+		}
+		err = canReadDraft(tx, draftID, userID)
+		if err != nil {
+			return err
+		}
+		where += ` and drafts.id = ?`
+		args = append(args, draftID)
+	}
+
+	query := `select
+                    drafts.id,
+                    drafts.name,
+                    cards.id,
+                    cards.pack,
+                    packs.round,
+                    seats.user,
+                    users.discord_name,
+                    original_seats.position,
+                    original_packs.round,
+                    events.round,
+                    events.modified,
+                    ` + makedraft.StoredCardColumns + `
+                  from cards
+                  ` + makedraft.StoredCardJoin + `
+                  join packs on packs.id = cards.pack
+                  join seats on seats.id = packs.seat
+                  join drafts on drafts.id = seats.draft
+                  join packs original_packs on original_packs.id = cards.original_pack
+                  join seats original_seats on original_seats.id = original_packs.original_seat
+                  left join users on users.id = seats.user
+                  left join events on events.card1 = cards.id
+                  where ` + where + `
+                  order by drafts.id, cards.id`
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("can't search: %s", err.Error())
+	}
+	defer rows.Close()
+
+	type foundCard struct {
+		result SearchResult
+		packID int64
+		round  int64
+		owner  sql.NullInt64
+	}
+	var found []foundCard
+	for rows.Next() {
+		var f foundCard
+		var ownerName sql.NullString
+		var pickRound sql.NullInt64
+		var pickModified sql.NullInt64
+		var card makedraft.StoredCard
+		err = rows.Scan(append([]interface{}{&f.result.DraftID, &f.result.DraftName, &f.result.Card.ID, &f.packID, &f.round,
+			&f.owner, &ownerName, &f.result.OriginalSeat, &f.result.OriginalRound, &pickRound, &pickModified}, card.Scan()...)...)
+		if err != nil {
+			return fmt.Errorf("can't search: %s", err.Error())
+		}
+		cardID := f.result.Card.ID
+		f.result.Card, err = card.CardData()
+		if err != nil {
+			return fmt.Errorf("can't read card %d: %s", cardID, err.Error())
+		}
+		f.result.Card.ID = cardID
+		if f.round == 0 {
+			f.result.OwnerID = f.owner.Int64
+			f.result.OwnerName = ownerName.String
+			if pickRound.Valid {
+				f.result.Round = pickRound.Int64
+				f.result.Pick = pickModified.Int64 - 15*(pickRound.Int64-1)
+			}
+		}
+		found = append(found, f)
+	}
+	rows.Close()
+
+	results := SearchResults{Cards: []SearchResult{}}
+	views := make(map[int64]searchView)
+	for _, f := range found {
+		view, ok := views[f.result.DraftID]
+		if !ok {
+			view, err = getSearchView(tx, f.result.DraftID, userID)
+			if err != nil {
+				return err
+			}
+			views[f.result.DraftID] = view
+		}
+		if view.hidden {
+			continue
+		}
+		mine := f.round == 0 && f.owner.Valid && f.owner.Int64 == userID
+		if !view.wholeDraft && !mine && f.packID != view.packID {
+			continue
+		}
+		if len(results.Cards) == searchLimit {
+			results.Truncated = true
+			break
+		}
+		results.Cards = append(results.Cards, f.result)
+	}
+
+	json.NewEncoder(w).Encode(results)
+	return nil
+}
+
+// getSearchView works out what a user can see of a draft, the same way GetFilteredJSON does.
+func getSearchView(tx *sql.Tx, draftID int64, userID int64) (searchView, error) {
+	var view searchView
+	var err error
+	view.hidden, err = isHiddenFrom(tx, draftID, userID)
+	if err != nil || view.hidden {
+		return view, err
+	}
+
+	view.wholeDraft, err = canSeeWholeDraft(tx, draftID, userID)
+	if err != nil || view.wholeDraft {
+		return view, err
+	}
+
+	view.packID, err = nextPackID(tx, draftID, userID)
+	return view, err
+}
+
+// nextPackID returns the pack doPick lets the user pick from next in a draft, or 0 if
+// there isn't one.
+func nextPackID(tx *sql.Tx, draftID int64, userID int64) (int64, error) {
+	query := `select
+                    v_packs.id
+                  from seats
+                  join v_packs on seats.id = v_packs.seat
+                  where seats.user = ?
+                    and seats.draft = ?
+                    and seats.round = v_packs.round
+                  order by v_packs.count desc
+                  limit 1`
+	var packID int64
+	err := tx.QueryRow(query, userID, draftID).Scan(&packID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return packID, err
+}
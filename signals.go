@@ -0,0 +1,314 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// ServeAPISignals serves the /api/signals/{draftId} endpoint, which reports what was
+// passed to each seat of a finished draft and what their neighbors were drafting.
+// Drafts that are still running would give away picks, so they get an error.
+func ServeAPISignals(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	re := regexp.MustCompile(`/api/signals/(\d+)`)
+	parseResult := re.FindStringSubmatch(r.URL.Path)
+	if parseResult == nil {
+		return fmt.Errorf("bad api url")
+	}
+	draftID, err := strconv.ParseInt(parseResult[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad api url: %s", err.Error())
+	}
+	err = canReadDraft(tx, draftID, userID)
+	if err != nil {
+		return err
+	}
+
+	var drafting int64
+	query := `select
+                    count(1)
+                  from seats
+                  where draft = ?
+                    and position is not null
+                    and round < 4`
+	err = tx.QueryRow(query, draftID).Scan(&drafting)
+	if err != nil {
+		return err
+	}
+	if drafting > 0 {
+		return fmt.Errorf("draft %d isn't over yet", draftID)
+	}
+
+	report, err := DraftSignals(tx, draftID)
+	if err != nil {
+		return err
+	}
+
+	json.NewEncoder(w).Encode(report)
+	return nil
+}
+
+// SignalsReport shows, for each seat of a finished draft, what was passed to them and
+// what the players passing to them were drafting.
+type SignalsReport struct {
+	DraftID   int64         `json:"draftId"`
+	DraftName string        `json:"draftName"`
+	Seats     []SeatSignals `json:"seats"`
+}
+
+// SeatSignals is one seat's part of a SignalsReport. Colors are the player's two main
+// colors, like "WU", and LockedAtPick is the pick from which they stayed their two main
+// colors for the rest of the draft.
+type SeatSignals struct {
+	Position     int64          `json:"position"`
+	PlayerID     int64          `json:"playerId"`
+	PlayerName   string         `json:"playerName"`
+	Colors       string         `json:"colors"`
+	LockedAtPick int            `json:"lockedAtPick"`
+	Rounds       []RoundSignals `json:"rounds"`
+}
+
+// RoundSignals is what a seat was passed in one round: every card they saw in packs
+// other players opened, by color (C for colorless), and the colors of the player
+// passing to them by the end of the round.
+type RoundSignals struct {
+	Round          int64                  `json:"round"`
+	Upstream       int64                  `json:"upstream"`
+	UpstreamColors string                 `json:"upstreamColors"`
+	Passed         map[string]ColorSignal `json:"passed"`
+}
+
+// ColorSignal is how many cards of a color were passed and their average rating.
+// Multicolored cards count for each of their colors.
+type ColorSignal struct {
+	Cards  int     `json:"cards"`
+	Rating float64 `json:"rating"`
+}
+
+// seatPick is a card a seat picked, in the round they picked it.
+type seatPick struct {
+	round int64
+	card  makedraft.CardData
+}
+
+// DraftSignals works out the signals report for a draft from its events and the
+// contents of its original packs.
+func DraftSignals(tx *sql.Tx, draftID int64) (SignalsReport, error) {
+	report := SignalsReport{DraftID: draftID}
+	err := tx.QueryRow(`select name from drafts where id = ?`, draftID).Scan(&report.DraftName)
+	if err == sql.ErrNoRows {
+		return report, fmt.Errorf("no draft %d", draftID)
+	} else if err != nil {
+		return report, err
+	}
+
+	query := `select
+                    seats.position,
+                    users.id,
+                    users.discord_name
+                  from seats
+                  left join users on users.id = seats.user
+                  where seats.draft = ?
+                    and seats.position is not null
+                  order by seats.position`
+	rows, err := tx.Query(query, draftID)
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var seat SeatSignals
+		var playerID sql.NullInt64
+		var playerName sql.NullString
+		err = rows.Scan(&seat.Position, &playerID, &playerName)
+		if err != nil {
+			rows.Close()
+			return report, err
+		}
+		seat.PlayerID = playerID.Int64
+		seat.PlayerName = playerName.String
+		report.Seats = append(report.Seats, seat)
+	}
+	rows.Close()
+	if len(report.Seats) == 0 {
+		return report, fmt.Errorf("draft %d has no seats", draftID)
+	}
+
+	query = `select
+                   cards.id,
+                   cards.original_pack,
+                   seats.position,
+                   ` + makedraft.StoredCardColumns + `
+                 from cards
+                 ` + makedraft.StoredCardJoin + `
+                 join packs on packs.id = cards.original_pack
+                 join seats on seats.id = packs.original_seat
+                 where seats.draft = ?`
+	rows, err = tx.Query(query, draftID)
+	if err != nil {
+		return report, err
+	}
+	cards := make(map[int64]makedraft.CardData)
+	packs := make(map[int64][]int64)
+	opener := make(map[int64]int64)
+	for rows.Next() {
+		var cardID, packID, position int64
+		var card makedraft.StoredCard
+		err = rows.Scan(append([]interface{}{&cardID, &packID, &position}, card.Scan()...)...)
+		if err != nil {
+			rows.Close()
+			return report, err
+		}
+		data, err := card.CardData()
+		if err != nil {
+			rows.Close()
+			return report, fmt.Errorf("can't read card %d: %s", cardID, err.Error())
+		}
+		cards[cardID] = data
+		packs[packID] = append(packs[packID], cardID)
+		opener[packID] = position
+	}
+	rows.Close()
+
+	query = `select
+                   events.position,
+                   events.round,
+                   events.card1,
+                   events.card2,
+                   cards.original_pack
+                 from events
+                 join cards on cards.id = events.card1
+                 where events.draft = ?
+                 order by events.id`
+	rows, err = tx.Query(query, draftID)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	picks := make(map[int64][]seatPick)
+	// passed[position][round] has every card passed to a seat, once.
+	passed := make(map[int64]map[int64]map[int64]bool)
+	taken := make(map[int64]bool)
+	for rows.Next() {
+		var position, round, card1, packID int64
+		var card2 sql.NullInt64
+		err = rows.Scan(&position, &round, &card1, &card2, &packID)
+		if err != nil {
+			return report, err
+		}
+		if opener[packID] != position {
+			if passed[position] == nil {
+				passed[position] = make(map[int64]map[int64]bool)
+			}
+			if passed[position][round] == nil {
+				passed[position][round] = make(map[int64]bool)
+			}
+			for _, cardID := range packs[packID] {
+				if !taken[cardID] {
+					passed[position][round][cardID] = true
+				}
+			}
+		}
+		taken[card1] = true
+		if card2.Valid {
+			taken[card2.Int64] = true
+		}
+		picks[position] = append(picks[position], seatPick{round: round, card: cards[card1]})
+	}
+	if err = rows.Err(); err != nil {
+		return report, err
+	}
+
+	seats := int64(len(report.Seats))
+	for i := range report.Seats {
+		seat := &report.Seats[i]
+		var cardsSoFar []makedraft.CardData
+		var running []string
+		for _, pick := range picks[seat.Position] {
+			cardsSoFar = append(cardsSoFar, pick.card)
+			running = append(running, mainColors(cardsSoFar))
+		}
+		seat.Colors = mainColors(cardsSoFar)
+		if seat.Colors != "" {
+			seat.LockedAtPick = len(running)
+			for seat.LockedAtPick > 1 && running[seat.LockedAtPick-2] == seat.Colors {
+				seat.LockedAtPick--
+			}
+		}
+
+		for round := int64(1); round <= 3; round++ {
+			// packs go left in rounds 1 and 3 and right in round 2, the same as the replay.
+			upstream := (seat.Position + seats - 1) % seats
+			if round == 2 {
+				upstream = (seat.Position + 1) % seats
+			}
+			var upstreamCards []makedraft.CardData
+			for _, pick := range picks[upstream] {
+				if pick.round <= round {
+					upstreamCards = append(upstreamCards, pick.card)
+				}
+			}
+
+			signals := RoundSignals{
+				Round:          round,
+				Upstream:       upstream,
+				UpstreamColors: mainColors(upstreamCards),
+				Passed:         make(map[string]ColorSignal),
+			}
+			for cardID := range passed[seat.Position][round] {
+				card := cards[cardID]
+				colors := card.Colors()
+				if len(colors) == 0 {
+					colors = []string{"C"}
+				}
+				for _, color := range colors {
+					signal := signals.Passed[color]
+					signal.Cards++
+					if card.Rating != nil {
+						signal.Rating += *card.Rating
+					}
+					signals.Passed[color] = signal
+				}
+			}
+			for color, signal := range signals.Passed {
+				signal.Rating /= float64(signal.Cards)
+				signals.Passed[color] = signal
+			}
+			seat.Rounds = append(seat.Rounds, signals)
+		}
+	}
+
+	return report, nil
+}
+
+// mainColors returns the two colors that show up most in cards, in WUBRG order,
+// or fewer if the cards don't have two colors. Ties go to the color earlier in WUBRG.
+func mainColors(cards []makedraft.CardData) string {
+	counts := make(map[string]int)
+	for _, card := range cards {
+		for _, color := range card.Colors() {
+			counts[color]++
+		}
+	}
+	colors := []string{"W", "U", "B", "R", "G"}
+	sort.SliceStable(colors, func(a, b int) bool {
+		return counts[colors[a]] > counts[colors[b]]
+	})
+	var top []string
+	for _, color := range colors[:2] {
+		if counts[color] > 0 {
+			top = append(top, color)
+		}
+	}
+	sort.SliceStable(top, func(a, b int) bool {
+		return strings.Index("WUBRG", top[a]) < strings.Index("WUBRG", top[b])
+	})
+	return strings.Join(top, "")
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"github.com/walkingeyerobot/r38/drafts"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
 // These structs are for supplying page data to .tmpl files
 
 // Draft describes a draft for the purposes of the index page.
@@ -10,6 +15,7 @@ type Draft struct {
 	Joined     bool
 	Joinable   bool
 	Replayable bool
+	Private    bool
 }
 
 // IndexPageData is the input to index.tmpl.
@@ -17,6 +23,7 @@ type IndexPageData struct {
 	Drafts  []Draft
 	ViewURL string
 	UserID  int64
+	IsAdmin bool
 }
 
 // ReplayPageData is the input to replay.tmpl.
@@ -42,10 +49,10 @@ type DraftJSON struct {
 
 // Seat is part of DraftJSON.
 type Seat struct {
-	Packs       [3][15]interface{} `json:"packs"`
-	PlayerName  string             `json:"playerName"`
-	PlayerID    int64              `json:"playerId"`
-	PlayerImage string             `json:"playerImage"`
+	Packs       [3][15]makedraft.CardData `json:"packs"`
+	PlayerName  string                    `json:"playerName"`
+	PlayerID    int64                     `json:"playerId"`
+	PlayerImage string                    `json:"playerImage"`
 }
 
 // DraftEvent is part of DraftJSON.
@@ -78,6 +85,43 @@ type DraftListEntry struct {
 	Name           string `json:"name"`
 	AvailableSeats int64  `json:"availableSeats"`
 	Status         string `json:"status"`
+	Private        bool   `json:"private,omitempty"`
+}
+
+// CreatedDraft is JSON returned to an admin after creating a new draft.
+type CreatedDraft struct {
+	DraftID       int64  `json:"draftId"`
+	DraftAttempts int    `json:"draftAttempts"`
+	PackAttempts  int    `json:"packAttempts"`
+	CubeVersion   int64  `json:"cubeVersion,omitempty"`
+	InviteLink    string `json:"inviteLink,omitempty"`
+}
+
+// SearchResults is JSON returned from a card search. Truncated is set when there were
+// more cards than the search returns.
+type SearchResults struct {
+	Cards     []SearchResult `json:"cards"`
+	Truncated bool           `json:"truncated"`
+}
+
+// SearchResult is a card found by a search, with where it started out. Once it's been
+// picked, Owner is who has it and Round and Pick are when they took it.
+type SearchResult struct {
+	DraftID       int64              `json:"draftId"`
+	DraftName     string             `json:"draftName"`
+	OriginalSeat  int64              `json:"originalSeat"`
+	OriginalRound int64              `json:"originalRound"`
+	OwnerID       int64              `json:"ownerId,omitempty"`
+	OwnerName     string             `json:"ownerName,omitempty"`
+	Round         int64              `json:"round,omitempty"`
+	Pick          int64              `json:"pick,omitempty"`
+	Card          makedraft.CardData `json:"card"`
+}
+
+// SuggestedPicks is JSON returned to a drafter asking which card to pick, best first.
+type SuggestedPicks struct {
+	DraftID     int64        `json:"draftId"`
+	Suggestions []Suggestion `json:"suggestions"`
 }
 
 // UserInfo is JSON passed to the client.
@@ -95,8 +139,70 @@ type PostedPick struct {
 }
 
 // PostedJoin is JSON accepted from the client when a user joins a draft.
+// Code is the invite code, which private drafts need.
 type PostedJoin struct {
-	ID int64 `json:"id"`
+	ID   int64  `json:"id"`
+	Code string `json:"code"`
+}
+
+// PostedCreateDraft is JSON accepted from an admin creating a new draft.
+// Flags use the same syntax as the flags in set json files and override them.
+// RoundSets names a set for each of the 3 rounds, and ChaosSets lists sets that
+// every pack is picked from at random. Either one replaces Set, and so does Cube,
+// which names a cube saved in the database, at CubeVersion or its latest version.
+// DisableSuggestions turns off pick suggestions for the draft, and Spectators sets how
+// people who aren't in it can watch it, as live, hidden, delayed:N or seat:N.
+// Private drafts are left out of draft lists and need an invite code to join.
+type PostedCreateDraft struct {
+	Set                string   `json:"set"`
+	RoundSets          []string `json:"roundSets"`
+	ChaosSets          []string `json:"chaosSets"`
+	Cube               string   `json:"cube"`
+	CubeVersion        int64    `json:"cubeVersion"`
+	Name               string   `json:"name"`
+	Seed               int64    `json:"seed"`
+	Flags              []string `json:"flags"`
+	DisableSuggestions bool     `json:"disableSuggestions"`
+	Spectators         string   `json:"spectators"`
+	Private            bool     `json:"private"`
+}
+
+// PostedCubeVersion is JSON accepted from an admin saving a new version of a cube.
+// It has either a whole set json file in Set, or Adds and Cuts by card id to apply
+// to the latest version.
+type PostedCubeVersion struct {
+	Cube string                 `json:"cube"`
+	Note string                 `json:"note"`
+	Set  *makedraft.DraftConfig `json:"set"`
+	Adds []makedraft.Card       `json:"adds"`
+	Cuts []string               `json:"cuts"`
+}
+
+// PostedSuggestions is JSON accepted from an admin turning pick suggestions on or off for a draft.
+type PostedSuggestions struct {
+	DraftID int64 `json:"draftId"`
+	Enabled bool  `json:"enabled"`
+}
+
+// PostedSpectators is JSON accepted from an admin setting how a draft can be watched,
+// as live, hidden, delayed:N or seat:N.
+type PostedSpectators struct {
+	DraftID    int64  `json:"draftId"`
+	Spectators string `json:"spectators"`
+}
+
+// PostedInvite is JSON accepted from an admin managing a draft's invite code. Action
+// is one of show, private, public, regenerate or revoke.
+type PostedInvite struct {
+	DraftID int64  `json:"draftId"`
+	Action  string `json:"action"`
+}
+
+// InviteCode is JSON returned to an admin managing a draft's invite code, with a
+// link players can use to join.
+type InviteCode struct {
+	drafts.Invite
+	Link string `json:"link,omitempty"`
 }
 
 // These structs are for exporting in bulk to .dek files.
@@ -114,16 +220,3 @@ type NameAndQuantity struct {
 	MTGO     string
 	Quantity int64
 }
-
-// R38CardData is the JSON passed to the client for card data.
-// Note that this does not describe everything that is in the data, just what we need
-type R38CardData struct {
-	MTGO     int64            `json:"mtgo_id"`
-	Scryfall ScryfallCardData `json:"scryfall"`
-}
-
-// ScryfallCardData is more JSON passed to the client for card data.
-// Note that this does not describe everything that is in the data, just what we need
-type ScryfallCardData struct {
-	Name string `json:"name"`
-}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/walkingeyerobot/r38/drafts"
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// ServeAPISuggest serves the /api/suggest/{draftId} endpoint, which ranks the cards in the
+// pack the user can pick from next. It only uses what the user has seen for themselves:
+// that pack, their own picks, and the cards they passed each time they picked.
+// Admins can turn suggestions off for a draft.
+func ServeAPISuggest(w http.ResponseWriter, r *http.Request, userID int64, tx *sql.Tx) error {
+	re := regexp.MustCompile(`/api/suggest/(\d+)`)
+	parseResult := re.FindStringSubmatch(r.URL.Path)
+	if parseResult == nil {
+		return fmt.Errorf("bad api url")
+	}
+	draftID, err := strconv.ParseInt(parseResult[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad api url: %s", err.Error())
+	}
+
+	enabled, err := drafts.Suggestions(tx, draftID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return fmt.Errorf("suggestions are turned off for this draft")
+	}
+
+	var position int64
+	err = tx.QueryRow(`select position from seats where draft = ? and "user" = ?`, draftID, userID).Scan(&position)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("you're not in this draft")
+	} else if err != nil {
+		return err
+	}
+	packID, err := nextPackID(tx, draftID, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `select
+                    cards.id,
+                    cards.pack,
+                    cards.original_pack,
+                    packs.round,
+                    seats.user,
+                    ` + makedraft.StoredCardColumns + `
+                  from cards
+                  ` + makedraft.StoredCardJoin + `
+                  join packs on packs.id = cards.pack
+                  join seats on seats.id = packs.seat
+                  where seats.draft = ?`
+	rows, err := tx.Query(query, draftID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cards := make(map[int64]makedraft.CardData)
+	originalPacks := make(map[int64][]int64)
+	var pack, picked []makedraft.CardData
+	for rows.Next() {
+		var cardID, cardPackID, originalPackID, round int64
+		var owner sql.NullInt64
+		var card makedraft.StoredCard
+		err = rows.Scan(append([]interface{}{&cardID, &cardPackID, &originalPackID, &round, &owner}, card.Scan()...)...)
+		if err != nil {
+			return err
+		}
+		data, err := card.CardData()
+		if err != nil {
+			return fmt.Errorf("can't read card %d: %s", cardID, err.Error())
+		}
+		data.ID = cardID
+		cards[cardID] = data
+		originalPacks[originalPackID] = append(originalPacks[originalPackID], cardID)
+		if packID != 0 && cardPackID == packID {
+			pack = append(pack, data)
+		} else if round == 0 && owner.Valid && owner.Int64 == userID {
+			picked = append(picked, data)
+		}
+	}
+	rows.Close()
+
+	// Replay the picks to find what was left in each pack the user picked from.
+	query = `select
+                   events.position,
+                   events.card1,
+                   events.card2,
+                   cards.original_pack
+                 from events
+                 join cards on cards.id = events.card1
+                 where events.draft = ?
+                 order by events.id`
+	rows, err = tx.Query(query, draftID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var passed []makedraft.CardData
+	taken := make(map[int64]bool)
+	for rows.Next() {
+		var eventPosition, card1, originalPackID int64
+		var card2 sql.NullInt64
+		err = rows.Scan(&eventPosition, &card1, &card2, &originalPackID)
+		if err != nil {
+			return err
+		}
+		taken[card1] = true
+		if card2.Valid {
+			taken[card2.Int64] = true
+		}
+		if eventPosition != position {
+			continue
+		}
+		for _, cardID := range originalPacks[originalPackID] {
+			if !taken[cardID] {
+				passed = append(passed, cards[cardID])
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(SuggestedPicks{
+		DraftID:     draftID,
+		Suggestions: SuggestPicks(pack, picked, passed),
+	})
+	return nil
+}
+
+// How much picked colors and passed colors count for, next to a card's rating.
+// A card in the only color you've picked gets suggestColorWeight added once you've
+// made suggestColorPicks picks, and a card in the only color you've passed loses
+// suggestPassedWeight.
+const (
+	suggestColorWeight  = 2.0
+	suggestColorPicks   = 10
+	suggestPassedWeight = 1.0
+)
+
+// Suggestion is a card in a pack, scored for how good a pick it is. Score is the sum
+// of the card's Rating, a Colors bonus for matching the colors already picked, and a
+// Passed penalty for colors the player has been passing to their neighbors.
+type Suggestion struct {
+	Card   makedraft.CardData `json:"card"`
+	Score  float64            `json:"score"`
+	Rating float64            `json:"rating"`
+	Colors float64            `json:"colors"`
+	Passed float64            `json:"passed"`
+}
+
+// SuggestPicks ranks the cards in a pack, best first, given the cards the player has
+// picked and the cards they've seen and passed. Colorless cards fit every deck.
+func SuggestPicks(pack []makedraft.CardData, picked []makedraft.CardData, passed []makedraft.CardData) []Suggestion {
+	pickedShares := colorShares(picked)
+	passedShares := colorShares(passed)
+	mostPicked := 0.0
+	for _, share := range pickedShares {
+		mostPicked = math.Max(mostPicked, share)
+	}
+	commitment := math.Min(1, float64(len(picked))/suggestColorPicks)
+
+	suggestions := []Suggestion{}
+	for _, card := range pack {
+		s := Suggestion{Card: card}
+		if card.Rating != nil {
+			s.Rating = *card.Rating
+		}
+
+		colors := card.Colors()
+		fit := 1.0
+		passedShare := 0.0
+		for _, color := range colors {
+			if mostPicked > 0 {
+				fit = math.Min(fit, pickedShares[color]/mostPicked)
+			}
+			passedShare += passedShares[color] / float64(len(colors))
+		}
+		s.Colors = suggestColorWeight * commitment * fit
+		if len(colors) > 0 && len(passed) > 0 {
+			// an even spread of passed colors is 1/5 each, which isn't a signal.
+			s.Passed = -suggestPassedWeight * (passedShare - 0.2)
+		}
+		s.Score = s.Rating + s.Colors + s.Passed
+		suggestions = append(suggestions, s)
+	}
+
+	sort.SliceStable(suggestions, func(a, b int) bool {
+		if suggestions[a].Score != suggestions[b].Score {
+			return suggestions[a].Score > suggestions[b].Score
+		}
+		return suggestions[a].Card.ID < suggestions[b].Card.ID
+	})
+	return suggestions
+}
+
+// colorShares is how much of each color is in cards, weighted by rating so good cards
+// count for more. The shares add up to 1, or are empty if no card has a color.
+func colorShares(cards []makedraft.CardData) map[string]float64 {
+	shares := make(map[string]float64)
+	total := 0.0
+	for _, card := range cards {
+		weight := 1.0
+		if card.Rating != nil {
+			weight += *card.Rating
+		}
+		colors := card.Colors()
+		for _, color := range colors {
+			shares[color] += weight / float64(len(colors))
+		}
+		if len(colors) > 0 {
+			total += weight
+		}
+	}
+	for color := range shares {
+		shares[color] /= total
+	}
+	return shares
+}
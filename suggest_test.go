@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/walkingeyerobot/r38/makedraft"
+)
+
+// suggestCard makes a card with an id, colors, and a rating unless rating is negative.
+func suggestCard(id int64, rating float64, colors ...string) makedraft.CardData {
+	card := makedraft.CardData{ID: id}
+	if colors == nil {
+		colors = []string{}
+	}
+	card.Scryfall.Colors = &colors
+	if rating >= 0 {
+		card.Rating = &rating
+	}
+	return card
+}
+
+// suggestCards makes n unrated cards of the same colors.
+func suggestCards(n int, colors ...string) []makedraft.CardData {
+	var cards []makedraft.CardData
+	for i := 0; i < n; i++ {
+		cards = append(cards, suggestCard(int64(100+i), -1, colors...))
+	}
+	return cards
+}
+
+func closeTo(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestColorShares(t *testing.T) {
+	tests := []struct {
+		name  string
+		cards []makedraft.CardData
+		want  map[string]float64
+	}{
+		{"no cards", nil, map[string]float64{}},
+		{"colorless only", suggestCards(3), map[string]float64{}},
+		{"one color", suggestCards(2, "W"), map[string]float64{"W": 1}},
+		{"multicolor splits its weight", suggestCards(1, "W", "U"), map[string]float64{"W": 0.5, "U": 0.5}},
+		{"colorless doesn't count", append(suggestCards(1, "B"), suggestCards(3)...), map[string]float64{"B": 1}},
+		{
+			name:  "rated cards count for more",
+			cards: []makedraft.CardData{suggestCard(1, 3, "R"), suggestCard(2, -1, "G")},
+			want:  map[string]float64{"R": 0.8, "G": 0.2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := colorShares(test.cards)
+			if len(got) != len(test.want) {
+				t.Fatalf("colorShares() = %v, want %v", got, test.want)
+			}
+			for color, share := range test.want {
+				if !closeTo(got[color], share) {
+					t.Errorf("colorShares() = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSuggestPicks(t *testing.T) {
+	type want struct {
+		id     int64
+		colors float64
+		passed float64
+	}
+	tests := []struct {
+		name   string
+		pack   []makedraft.CardData
+		picked []makedraft.CardData
+		passed []makedraft.CardData
+		want   []want
+	}{
+		{
+			name: "empty pack",
+			want: []want{},
+		},
+		{
+			name: "rating first pick",
+			pack: []makedraft.CardData{suggestCard(1, 1, "W"), suggestCard(2, 3, "U"), suggestCard(3, -1, "B")},
+			want: []want{{2, 0, 0}, {1, 0, 0}, {3, 0, 0}},
+		},
+		{
+			name: "ties by id",
+			pack: []makedraft.CardData{suggestCard(2, 1, "W"), suggestCard(1, 1, "U")},
+			want: []want{{1, 0, 0}, {2, 0, 0}},
+		},
+		{
+			name:   "picked color beats a better card",
+			pack:   []makedraft.CardData{suggestCard(1, 2.5, "U"), suggestCard(2, 1, "W")},
+			picked: suggestCards(10, "W"),
+			want:   []want{{2, 2, 0}, {1, 0, 0}},
+		},
+		{
+			name:   "commitment grows with picks",
+			pack:   []makedraft.CardData{suggestCard(1, 0, "W")},
+			picked: suggestCards(5, "W"),
+			want:   []want{{1, 1, 0}},
+		},
+		{
+			name:   "second color fits in part",
+			pack:   []makedraft.CardData{suggestCard(1, 0, "W"), suggestCard(2, 0, "U"), suggestCard(3, 0, "W", "U")},
+			picked: append(suggestCards(8, "W"), suggestCards(4, "U")...),
+			want:   []want{{1, 2, 0}, {2, 1, 0}, {3, 1, 0}},
+		},
+		{
+			name:   "colorless fits every deck",
+			pack:   []makedraft.CardData{suggestCard(1, 0)},
+			picked: suggestCards(10, "G"),
+			want:   []want{{1, 2, 0}},
+		},
+		{
+			name:   "passed color loses",
+			pack:   []makedraft.CardData{suggestCard(1, 1, "U"), suggestCard(2, 0.5, "W"), suggestCard(3, 0.5)},
+			passed: suggestCards(4, "U"),
+			want:   []want{{2, 0, 0.2}, {3, 0, 0}, {1, 0, -0.8}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SuggestPicks(test.pack, test.picked, test.passed)
+			if len(got) != len(test.want) {
+				t.Fatalf("SuggestPicks() returned %d suggestions, want %d", len(got), len(test.want))
+			}
+			for i, w := range test.want {
+				s := got[i]
+				if s.Card.ID != w.id || !closeTo(s.Colors, w.colors) || !closeTo(s.Passed, w.passed) {
+					t.Errorf("suggestion %d = card %d colors %v passed %v, want card %d colors %v passed %v",
+						i, s.Card.ID, s.Colors, s.Passed, w.id, w.colors, w.passed)
+				}
+				if !closeTo(s.Score, s.Rating+s.Colors+s.Passed) {
+					t.Errorf("suggestion %d score %v isn't the sum of its parts", i, s.Score)
+				}
+			}
+		})
+	}
+}